@@ -0,0 +1,22 @@
+package audit
+
+import (
+	"log"
+
+	"github.com/CUknot/network_backend/database"
+	"github.com/CUknot/network_backend/models"
+)
+
+// Actions recorded in the audit log
+const (
+	ActionLoginLockout      = "login_lockout"
+	ActionRetentionPolicy   = "retention_policy_changed"
+	ActionRetentionDeletion = "retention_deletion"
+)
+
+// Record writes an entry to the audit log
+func Record(entry models.AuditLog) {
+	if err := database.DB.Create(&entry).Error; err != nil {
+		log.Printf("error writing audit log entry %q: %v", entry.Action, err)
+	}
+}
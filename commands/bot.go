@@ -0,0 +1,124 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/CUknot/network_backend/database"
+	"github.com/CUknot/network_backend/messaging"
+	"github.com/CUknot/network_backend/models"
+	"github.com/CUknot/network_backend/safehttp"
+	"github.com/CUknot/network_backend/webhooks"
+)
+
+const (
+	// Largest bot response body read
+	maxBotResponse = 64 << 10
+	// Most attachments kept from a bot reply
+	maxBotAttachments = 20
+	// Longest sender name kept from a bot reply, as for incoming webhooks
+	maxBotUsername = 80
+)
+
+// Client is the HTTP client used to call bot endpoints. It refuses private
+// addresses and doesn't follow redirects.
+var Client = safehttp.NewClient(5*time.Second, 0)
+
+// botRequest is the JSON body sent to a bot's endpoint
+type botRequest struct {
+	Command string                 `json:"command"`
+	Text    string                 `json:"text"`
+	RoomID  uint                   `json:"room_id"`
+	User    map[string]interface{} `json:"user"`
+}
+
+// botResponse follows Slack's slash command responses. Only an "in_channel"
+// response_type is posted to the room; anything else is ephemeral.
+type botResponse struct {
+	Text         string              `json:"text"`
+	ResponseType string              `json:"response_type"`
+	Username     string              `json:"username"`
+	Attachments  []models.Attachment `json:"attachments"`
+}
+
+// callBot forwards a command to an externally registered bot and maps its reply onto a response
+func callBot(bot models.BotCommand, ctx Context) (Response, error) {
+	var user models.User
+	if err := database.DB.First(&user, ctx.UserID).Error; err != nil {
+		return Response{}, err
+	}
+
+	body, err := json.Marshal(botRequest{
+		Command: "/" + bot.Name,
+		Text:    ctx.Args,
+		RoomID:  ctx.RoomID,
+		User:    webhooks.UserData(user),
+	})
+	if err != nil {
+		return Response{}, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req, err := http.NewRequest(http.MethodPost, bot.URL, bytes.NewReader(body))
+	if err != nil {
+		return Response{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", webhooks.Sign(bot.Secret, timestamp, body))
+
+	// A bot that is down is the invoker's problem to hear about, not a server error
+	unavailable := Response{Ephemeral: fmt.Sprintf("/%s didn't respond, please try again later", bot.Name)}
+	resp, err := Client.Do(req)
+	if err != nil {
+		return unavailable, nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return unavailable, nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBotResponse))
+	if err != nil {
+		return unavailable, nil
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return Response{}, nil
+	}
+
+	// Only JSON replies are used, so whatever else the endpoint returns is never shown
+	var reply botResponse
+	if err := json.Unmarshal(data, &reply); err != nil {
+		return Response{Ephemeral: fmt.Sprintf("/%s sent a reply that couldn't be read", bot.Name)}, nil
+	}
+	if reply.ResponseType != "in_channel" {
+		return Response{Ephemeral: reply.Text}, nil
+	}
+	if utf8.RuneCountInString(reply.Username) > maxBotUsername {
+		reply.Username = string([]rune(reply.Username)[:maxBotUsername])
+	}
+	if len(reply.Attachments) > maxBotAttachments {
+		reply.Attachments = reply.Attachments[:maxBotAttachments]
+	}
+	reply.Attachments = messaging.SanitizeAttachments(reply.Attachments)
+	if reply.Text == "" && len(reply.Attachments) == 0 {
+		return Response{}, nil
+	}
+
+	content := reply.Text
+	if content == "" {
+		content = "/" + bot.Name
+	}
+	return Response{Message: &models.Message{
+		Content:     content,
+		UserID:      bot.UserID,
+		SenderName:  reply.Username,
+		Attachments: reply.Attachments,
+	}}, nil
+}
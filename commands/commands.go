@@ -0,0 +1,150 @@
+package commands
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/CUknot/network_backend/database"
+	"github.com/CUknot/network_backend/messaging"
+	"github.com/CUknot/network_backend/models"
+	"github.com/CUknot/network_backend/websocket"
+)
+
+// Context describes a single command invocation
+type Context struct {
+	UserID uint
+	RoomID uint
+	Name   string
+	Args   string
+}
+
+// Response is the outcome of a command. Message, if set, is posted to the room
+// (by the invoker unless it names another author), and Ephemeral is shown only
+// to the invoker.
+type Response struct {
+	Message   *models.Message `json:"message,omitempty"`
+	Ephemeral string          `json:"ephemeral,omitempty"`
+}
+
+// Handler runs a command. User mistakes such as bad arguments are reported
+// through an ephemeral response; errors are reserved for internal failures.
+type Handler func(ctx Context) (Response, error)
+
+// Command is a built-in slash command
+type Command struct {
+	Name        string
+	Usage       string
+	Description string
+	Handler     Handler
+}
+
+// EphemeralEvent is pushed to the invoker's connections for ephemeral responses
+type EphemeralEvent struct {
+	RoomID  uint   `json:"room_id"`
+	Command string `json:"command"`
+	Text    string `json:"text"`
+}
+
+var (
+	registry   = make(map[string]Command)
+	registryMu sync.RWMutex
+)
+
+// Register adds a built-in command, replacing any command with the same name
+func Register(command Command) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[strings.ToLower(command.Name)] = command
+}
+
+// Builtins returns the registered built-in commands sorted by name
+func Builtins() []Command {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	commands := make([]Command, 0, len(registry))
+	for _, command := range registry {
+		commands = append(commands, command)
+	}
+	sort.Slice(commands, func(i, j int) bool { return commands[i].Name < commands[j].Name })
+	return commands
+}
+
+// IsBuiltin reports whether a built-in command is registered under name
+func IsBuiltin(name string) bool {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	_, ok := registry[strings.ToLower(name)]
+	return ok
+}
+
+// Parse splits message content into a command name and its arguments. Content
+// starting with "//" is an escaped message rather than a command.
+func Parse(content string) (string, string, bool) {
+	content = strings.TrimSpace(content)
+	if !strings.HasPrefix(content, "/") || strings.HasPrefix(content, "//") {
+		return "", "", false
+	}
+
+	name, args, _ := strings.Cut(content[1:], " ")
+	if name == "" {
+		return "", "", false
+	}
+	return strings.ToLower(name), strings.TrimSpace(args), true
+}
+
+// Unescape turns an escaped "//" message back into one starting with a single "/"
+func Unescape(content string) string {
+	if trimmed := strings.TrimSpace(content); strings.HasPrefix(trimmed, "//") {
+		return trimmed[1:]
+	}
+	return content
+}
+
+// Execute runs a command in a room, posts any message it produces, and sends
+// any ephemeral response to the invoker's connections
+func Execute(ctx Context) (Response, error) {
+	response, err := run(ctx)
+	if err != nil {
+		return response, err
+	}
+
+	if response.Message != nil {
+		message := response.Message
+		message.RoomID = ctx.RoomID
+		if message.UserID == 0 {
+			message.UserID = ctx.UserID
+		}
+		if err := messaging.Post(message); err != nil {
+			return response, err
+		}
+	}
+
+	if response.Ephemeral != "" {
+		websocket.SendToUser(ctx.UserID, "ephemeral", EphemeralEvent{
+			RoomID:  ctx.RoomID,
+			Command: ctx.Name,
+			Text:    response.Ephemeral,
+		})
+	}
+	return response, nil
+}
+
+// run finds the handler for a command, preferring built-ins over the room's bot commands
+func run(ctx Context) (Response, error) {
+	registryMu.RLock()
+	command, ok := registry[ctx.Name]
+	registryMu.RUnlock()
+	if ok {
+		return command.Handler(ctx)
+	}
+
+	var bot models.BotCommand
+	if err := database.DB.Where("room_id = ? AND name = ?", ctx.RoomID, ctx.Name).First(&bot).Error; err == nil {
+		return callBot(bot, ctx)
+	}
+
+	return Response{Ephemeral: fmt.Sprintf("Unknown command /%s. Try /help", ctx.Name)}, nil
+}
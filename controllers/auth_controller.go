@@ -1,24 +1,27 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/CUknot/network_backend/database"
 	"github.com/CUknot/network_backend/models"
-	"github.com/CUknot/network_backend/utils"
 	"github.com/gin-gonic/gin"
 )
 
 type RegisterInput struct {
-	Username string `json:"username" binding:"required"`
-	Tag      string `json:"tag" binding:"required,len=4"`
-	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=6"`
+	Username   string `json:"username" binding:"required"`
+	Tag        string `json:"tag" binding:"omitempty,len=4"`
+	Email      string `json:"email" binding:"required,email"`
+	Password   string `json:"password" binding:"required,min=6"`
+	DeviceName string `json:"device_name"`
 }
 
 type LoginInput struct {
-	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required"`
+	Email      string `json:"email" binding:"required,email"`
+	Password   string `json:"password" binding:"required"`
+	DeviceName string `json:"device_name"`
 }
 
 // Register handles user registration
@@ -44,13 +47,34 @@ func Register(c *gin.Context) {
 		Password: input.Password,
 	}
 
-	if result := database.DB.Create(&user); result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+	// Allocate a tag when the client did not ask for one
+	var err error
+	if input.Tag == "" {
+		err = createUserWithFreeTag(&user)
+	} else {
+		err = database.DB.Create(&user).Error
+	}
+
+	if err != nil {
+		switch {
+		case database.IsUniqueViolation(err, usernameTagIndex):
+			suggestions, _ := freeTags(input.Username, tagSuggestionCount)
+			c.JSON(http.StatusConflict, gin.H{
+				"error":       "Username and tag combination is already taken",
+				"suggestions": suggestions,
+			})
+		case database.IsUniqueViolation(err, ""):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User with this email already exists"})
+		case errors.Is(err, errNoFreeTags):
+			c.JSON(http.StatusConflict, gin.H{"error": "No tags are left for this username, please choose another"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		}
 		return
 	}
 
-	// Generate token
-	token, err := utils.GenerateToken(user.ID)
+	// Start a session and generate its token
+	token, err := startSession(c, user.ID, input.DeviceName)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
@@ -76,21 +100,36 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	// Refuse attempts while the account or client address is throttled. Allowed
+	// attempts count as failures until the password checks out.
+	attempt, retryAfter := startLoginAttempt(input.Email, c.ClientIP())
+	if retryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error":       "Too many failed login attempts, please try again later",
+			"retry_after": retryAfter,
+		})
+		return
+	}
+
 	// Find user by email
 	var user models.User
 	if result := database.DB.Where("email = ?", input.Email).First(&user); result.Error != nil {
+		attempt.failed(nil)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
 		return
 	}
 
 	// Validate password
 	if err := user.ValidatePassword(input.Password); err != nil {
+		attempt.failed(&user.ID)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
 		return
 	}
+	attempt.succeeded()
 
-	// Generate token
-	token, err := utils.GenerateToken(user.ID)
+	// Start a session and generate its token
+	token, err := startSession(c, user.ID, input.DeviceName)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/CUknot/network_backend/database"
+	"github.com/CUknot/network_backend/models"
+	"github.com/CUknot/network_backend/websocket"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GetBlocks returns the users blocked by the authenticated user
+func GetBlocks(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	var blocks []models.Block
+	if err := database.DB.Where("blocker_id = ?", userID).
+		Preload("Blocked").
+		Order("created_at DESC").
+		Find(&blocks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch blocked users"})
+		return
+	}
+
+	results := make([]gin.H, len(blocks))
+	for i, block := range blocks {
+		result := publicUser(block.Blocked)
+		result["blocked_at"] = block.CreatedAt
+		results[i] = result
+	}
+
+	c.JSON(http.StatusOK, gin.H{"blocks": results})
+}
+
+// BlockUser blocks a user and ends any friendship with them
+func BlockUser(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+	blockedID, err := strconv.ParseUint(c.Param("userId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	if uint(blockedID) == userID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You can't block yourself"})
+		return
+	}
+
+	if _, err := resolveUser(uint(blockedID), ""); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		block := models.Block{
+			BlockerID: userID,
+			BlockedID: uint(blockedID),
+		}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&block).Error; err != nil {
+			return err
+		}
+
+		// Friendships and pending requests do not survive a block
+		return tx.Where("(sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ?)", userID, blockedID, blockedID, userID).
+			Delete(&models.FriendRequest{}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to block user"})
+		return
+	}
+
+	websocket.SetBlocked(userID, uint(blockedID), true)
+
+	c.JSON(http.StatusOK, gin.H{"message": "User blocked successfully"})
+}
+
+// UnblockUser removes a block
+func UnblockUser(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+	blockedID, err := strconv.ParseUint(c.Param("userId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	result := database.DB.Where("blocker_id = ? AND blocked_id = ?", userID, blockedID).Delete(&models.Block{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unblock user"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User is not blocked"})
+		return
+	}
+
+	websocket.SetBlocked(userID, uint(blockedID), false)
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unblocked successfully"})
+}
+
+// hasBlocked reports whether blocker has blocked the other user
+func hasBlocked(blockerID, blockedID uint) bool {
+	var count int64
+	database.DB.Model(&models.Block{}).Where("blocker_id = ? AND blocked_id = ?", blockerID, blockedID).Count(&count)
+	return count > 0
+}
+
+// blockedIDs returns a subquery selecting the users blocked by the user
+func blockedIDs(userID uint) *gorm.DB {
+	return database.DB.Model(&models.Block{}).Select("blocked_id").Where("blocker_id = ?", userID)
+}
@@ -0,0 +1,172 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/CUknot/network_backend/database"
+	"github.com/CUknot/network_backend/messaging"
+	"github.com/CUknot/network_backend/models"
+	"github.com/gin-gonic/gin"
+)
+
+// Unique index that allows one bookmark per user and message
+const bookmarkIndex = "idx_user_bookmark"
+
+type CreateBookmarkInput struct {
+	MessageID uint   `json:"message_id" binding:"required"`
+	Note      string `json:"note" binding:"max=500"`
+}
+
+type UpdateBookmarkInput struct {
+	Note string `json:"note" binding:"max=500"`
+}
+
+// GetBookmarks returns the authenticated user's bookmarks, newest first. Bookmarks
+// in rooms the user has left are hidden until they rejoin.
+func GetBookmarks(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	limit := pageSize(c)
+	query := database.DB.Joins("JOIN messages ON messages.id = bookmarks.message_id").
+		Where("bookmarks.user_id = ?", userID).
+		Where("bookmarks.room_id IN (?)", database.DB.Model(&models.RoomUser{}).Select("room_id").Where("user_id = ?", userID)).
+		Where("messages.user_id NOT IN (?)", blockedIDs(userID))
+
+	if roomID, err := strconv.ParseUint(c.Query("room_id"), 10, 32); err == nil {
+		query = query.Where("bookmarks.room_id = ?", roomID)
+	}
+	if before, err := strconv.ParseUint(c.Query("before"), 10, 32); err == nil {
+		query = query.Where("bookmarks.id < ?", before)
+	}
+
+	var bookmarks []models.Bookmark
+	if err := query.Order("bookmarks.id DESC").
+		Limit(limit).
+		Preload("Message").
+		Preload("Message.User").
+		Preload("Message.Poll.Options", messaging.PollOptionOrder).
+		Find(&bookmarks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch bookmarks"})
+		return
+	}
+
+	// Messages are shown as they would be in their room
+	messages := make([]*models.Message, len(bookmarks))
+	var polls []*models.Poll
+	for i := range bookmarks {
+		messages[i] = bookmarks[i].Message
+		if bookmarks[i].Message.Poll != nil {
+			polls = append(polls, bookmarks[i].Message.Poll)
+		}
+	}
+	if err := messaging.HideQuotes(messages, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch bookmarks"})
+		return
+	}
+	if err := loadPollResults(polls, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch poll results"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"bookmarks": bookmarks})
+}
+
+// CreateBookmark bookmarks a message the authenticated user can see
+func CreateBookmark(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	var input CreateBookmarkInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	message, ok := findVisibleMessage(input.MessageID, userID)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return
+	}
+
+	bookmark := models.Bookmark{
+		UserID:    userID,
+		MessageID: message.ID,
+		RoomID:    message.RoomID,
+		Note:      input.Note,
+	}
+	if err := database.DB.Create(&bookmark).Error; err != nil {
+		if database.IsUniqueViolation(err, bookmarkIndex) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Message is already bookmarked"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create bookmark"})
+		return
+	}
+
+	// The message is shown as it would be in its room
+	if err := messaging.HideQuotes([]*models.Message{&message}, userID); err != nil {
+		message.Quote = nil
+	}
+	bookmark.Message = &message
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":  "Bookmark created successfully",
+		"bookmark": bookmark,
+	})
+}
+
+// UpdateBookmark changes the note on one of the authenticated user's bookmarks
+func UpdateBookmark(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	bookmarkID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bookmark ID"})
+		return
+	}
+
+	var bookmark models.Bookmark
+	if err := database.DB.Where("id = ? AND user_id = ?", bookmarkID, userID).First(&bookmark).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bookmark not found"})
+		return
+	}
+
+	var input UpdateBookmarkInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := database.DB.Model(&bookmark).Update("note", input.Note).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update bookmark"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Bookmark updated successfully",
+		"bookmark": bookmark,
+	})
+}
+
+// DeleteBookmark removes one of the authenticated user's bookmarks
+func DeleteBookmark(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	bookmarkID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bookmark ID"})
+		return
+	}
+
+	result := database.DB.Where("id = ? AND user_id = ?", bookmarkID, userID).Delete(&models.Bookmark{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete bookmark"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bookmark not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Bookmark deleted successfully"})
+}
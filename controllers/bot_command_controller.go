@@ -0,0 +1,161 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/CUknot/network_backend/commands"
+	"github.com/CUknot/network_backend/database"
+	"github.com/CUknot/network_backend/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CreateBotCommandInput struct {
+	Name        string `json:"name" binding:"required,alphanum,max=32"`
+	Description string `json:"description" binding:"max=255"`
+	URL         string `json:"url" binding:"required,url,startswith=http"`
+}
+
+// GetBotCommands returns the bot commands registered in a room
+func GetBotCommands(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+	roomID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid room ID"})
+		return
+	}
+
+	// Check if user is a member of the room
+	var roomUser models.RoomUser
+	if err := database.DB.Where("room_id = ? AND user_id = ?", roomID, userID).First(&roomUser).Error; err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have access to this room"})
+		return
+	}
+
+	var bots []models.BotCommand
+	if err := database.DB.Where("room_id = ?", roomID).Preload("User").Order("name ASC").Find(&bots).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch commands"})
+		return
+	}
+
+	// Only admins see where commands are sent
+	if !isRoomAdmin(uint(roomID), userID) {
+		for i := range bots {
+			bots[i].URL = ""
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"commands": bots})
+}
+
+// CreateBotCommand registers a slash command that is answered by an external bot.
+// The secret its requests are signed with is only returned here.
+func CreateBotCommand(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+	roomID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid room ID"})
+		return
+	}
+
+	if !isRoomAdmin(uint(roomID), userID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only room admins can manage commands"})
+		return
+	}
+
+	var input CreateBotCommandInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	name := strings.ToLower(input.Name)
+	if commands.IsBuiltin(name) {
+		c.JSON(http.StatusConflict, gin.H{"error": "/" + name + " is a built-in command"})
+		return
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create command"})
+		return
+	}
+
+	bot, err := createBotUser(name)
+	if err != nil {
+		if err == errNoFreeTags {
+			c.JSON(http.StatusConflict, gin.H{"error": "No tags are left for this name, please choose another"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create command"})
+		return
+	}
+
+	command := models.BotCommand{
+		RoomID:      uint(roomID),
+		Name:        name,
+		Description: input.Description,
+		URL:         input.URL,
+		Secret:      secret,
+		UserID:      bot.ID,
+		User:        bot,
+		CreatedBy:   userID,
+	}
+	if err := database.DB.Omit("User").Create(&command).Error; err != nil {
+		retireBotUsers(database.DB, []uint{bot.ID})
+		if database.IsUniqueViolation(err, "idx_room_command") {
+			c.JSON(http.StatusConflict, gin.H{"error": "/" + name + " already exists in this room"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create command"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Command created successfully",
+		"command": command,
+		"secret":  secret,
+	})
+}
+
+// DeleteBotCommand removes a bot command from a room
+func DeleteBotCommand(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+	roomID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid room ID"})
+		return
+	}
+
+	if !isRoomAdmin(uint(roomID), userID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only room admins can manage commands"})
+		return
+	}
+
+	commandID, err := strconv.ParseUint(c.Param("commandId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid command ID"})
+		return
+	}
+
+	var command models.BotCommand
+	if err := database.DB.Where("id = ? AND room_id = ?", commandID, roomID).First(&command).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Command not found"})
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&command).Error; err != nil {
+			return err
+		}
+		return retireBotUsers(tx, []uint{command.UserID})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete command"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Command deleted successfully"})
+}
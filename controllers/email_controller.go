@@ -0,0 +1,125 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/CUknot/network_backend/database"
+	"github.com/CUknot/network_backend/mailer"
+	"github.com/CUknot/network_backend/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// How long an email change can be confirmed
+const emailChangeTTL = 24 * time.Hour
+
+var errEmailTaken = errors.New("email is already taken")
+
+type ConfirmEmailChangeInput struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// requestEmailChange records a pending change of a user's email and sends a
+// confirmation link to the new address, replacing any earlier pending change
+func requestEmailChange(tx *gorm.DB, user models.User, email string) error {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return err
+	}
+	token := hex.EncodeToString(secret)
+
+	change := models.EmailChange{
+		UserID:    user.ID,
+		Email:     email,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(emailChangeTTL),
+	}
+	if err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"email", "token_hash", "expires_at", "created_at"}),
+	}).Create(&change).Error; err != nil {
+		return err
+	}
+
+	appURL := os.Getenv("APP_URL")
+	if appURL == "" {
+		appURL = "http://localhost:3000"
+	}
+	link := appURL + "/confirm-email?token=" + url.QueryEscape(token)
+
+	return mailer.Default.Send(mailer.Email{
+		To:      email,
+		Subject: "Confirm your new email address",
+		Text: fmt.Sprintf("Hi %s,\n\nConfirm that you want to use this address for your account:\n%s\n\n"+
+			"The link expires in 24 hours. If you didn't ask for this, ignore this email.\n", user.Username, link),
+	})
+}
+
+// pendingEmail returns the address a user asked to change their email to, if it can still be confirmed
+func pendingEmail(userID uint) string {
+	var change models.EmailChange
+	if err := database.DB.Where("user_id = ? AND expires_at > ?", userID, time.Now()).First(&change).Error; err != nil {
+		return ""
+	}
+	return change.Email
+}
+
+// ConfirmEmailChange applies a pending email change using the token sent to the new address
+func ConfirmEmailChange(c *gin.Context) {
+	var input ConfirmEmailChangeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var change models.EmailChange
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ? AND expires_at > ?", hashToken(input.Token), time.Now()).
+			First(&change).Error; err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&models.User{}).Where("email = ? AND id != ?", change.Email, change.UserID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return errEmailTaken
+		}
+
+		if err := tx.Model(&models.User{}).Where("id = ?", change.UserID).Update("email", change.Email).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&change).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired confirmation token"})
+		return
+	}
+	if errors.Is(err, errEmailTaken) || database.IsUniqueViolation(err, "") {
+		c.JSON(http.StatusConflict, gin.H{"error": "User with this email already exists"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to confirm email change"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email changed successfully"})
+}
+
+// hashToken returns the hash a secret token, such as an email confirmation token, is stored under
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
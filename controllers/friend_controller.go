@@ -0,0 +1,290 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/CUknot/network_backend/database"
+	"github.com/CUknot/network_backend/models"
+	"github.com/CUknot/network_backend/websocket"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type SendFriendRequestInput struct {
+	UserID uint   `json:"user_id"`
+	Handle string `json:"handle"`
+}
+
+// GetFriends returns the authenticated user's friends with their online status
+func GetFriends(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	var friends []models.User
+	if err := database.DB.Where("id IN (?) AND deleted_at IS NULL", friendIDs(userID)).
+		Order("username ASC, tag ASC").
+		Find(&friends).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch friends"})
+		return
+	}
+
+	results := make([]gin.H, len(friends))
+	for i, friend := range friends {
+		result := publicUser(friend)
+		result["online"] = websocket.IsOnline(friend.ID)
+		results[i] = result
+	}
+
+	c.JSON(http.StatusOK, gin.H{"friends": results})
+}
+
+// RemoveFriend ends a friendship
+func RemoveFriend(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+	friendID, err := strconv.ParseUint(c.Param("userId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	result := database.DB.Where("status = ?", models.FriendRequestAccepted).
+		Where("(sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ?)", userID, friendID, friendID, userID).
+		Delete(&models.FriendRequest{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove friend"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Friend not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Friend removed successfully"})
+}
+
+// GetFriendRequests returns the authenticated user's pending incoming and outgoing friend requests
+func GetFriendRequests(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	var incoming []models.FriendRequest
+	if err := database.DB.Where("receiver_id = ? AND status = ?", userID, models.FriendRequestPending).
+		Where("sender_id NOT IN (?)", blockedIDs(userID)).
+		Preload("Sender").
+		Order("created_at DESC").
+		Find(&incoming).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch friend requests"})
+		return
+	}
+
+	var outgoing []models.FriendRequest
+	if err := database.DB.Where("sender_id = ? AND status = ?", userID, models.FriendRequestPending).
+		Preload("Receiver").
+		Order("created_at DESC").
+		Find(&outgoing).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch friend requests"})
+		return
+	}
+
+	incomingViews := make([]gin.H, len(incoming))
+	for i, request := range incoming {
+		incomingViews[i] = friendRequestView(request)
+	}
+	outgoingViews := make([]gin.H, len(outgoing))
+	for i, request := range outgoing {
+		outgoingViews[i] = friendRequestView(request)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"incoming": incomingViews,
+		"outgoing": outgoingViews,
+	})
+}
+
+// SendFriendRequest sends a friend request to a user given by ID or handle
+func SendFriendRequest(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	var input SendFriendRequestInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	targetID, err := resolveUser(input.UserID, input.Handle)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if targetID == userID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You can't send a friend request to yourself"})
+		return
+	}
+	if hasBlocked(userID, targetID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You have blocked this user"})
+		return
+	}
+
+	// Look for any existing request between the two users
+	var existing models.FriendRequest
+	err = database.DB.Where("(sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ?)", userID, targetID, targetID, userID).
+		First(&existing).Error
+	if err == nil {
+		switch {
+		case existing.Status == models.FriendRequestAccepted:
+			c.JSON(http.StatusConflict, gin.H{"error": "You are already friends"})
+		case existing.SenderID == userID:
+			c.JSON(http.StatusConflict, gin.H{"error": "Friend request already sent"})
+		default:
+			// The other user already asked, so sending back accepts their request
+			acceptFriendRequest(c, &existing)
+		}
+		return
+	}
+
+	request := models.FriendRequest{
+		SenderID:   userID,
+		ReceiverID: targetID,
+		Status:     models.FriendRequestPending,
+	}
+	if err := database.DB.Create(&request).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send friend request"})
+		return
+	}
+
+	// A user who blocked the sender never hears about the request
+	database.DB.Preload("Sender").Preload("Receiver").First(&request, request.ID)
+	if !hasBlocked(targetID, userID) {
+		websocket.SendToUser(targetID, "friend_request", friendRequestView(request))
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Friend request sent successfully",
+		"request": friendRequestView(request),
+	})
+}
+
+// AcceptFriendRequest accepts a pending friend request sent to the authenticated user
+func AcceptFriendRequest(c *gin.Context) {
+	request, ok := findFriendRequest(c, "receiver_id")
+	if !ok {
+		return
+	}
+
+	acceptFriendRequest(c, request)
+}
+
+// DeclineFriendRequest declines a pending friend request sent to the authenticated user
+func DeclineFriendRequest(c *gin.Context) {
+	request, ok := findFriendRequest(c, "receiver_id")
+	if !ok {
+		return
+	}
+
+	if err := database.DB.Delete(request).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decline friend request"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Friend request declined"})
+}
+
+// CancelFriendRequest withdraws a pending friend request sent by the authenticated user
+func CancelFriendRequest(c *gin.Context) {
+	request, ok := findFriendRequest(c, "sender_id")
+	if !ok {
+		return
+	}
+
+	if err := database.DB.Delete(request).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel friend request"})
+		return
+	}
+
+	websocket.SendToUser(request.ReceiverID, "friend_request_cancelled", gin.H{"id": request.ID})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Friend request cancelled"})
+}
+
+// findFriendRequest loads the pending request in the :id param where the
+// authenticated user is the party named by column, responding on failure
+func findFriendRequest(c *gin.Context, column string) (*models.FriendRequest, bool) {
+	userID := c.MustGet("userID").(uint)
+	requestID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid friend request ID"})
+		return nil, false
+	}
+
+	var request models.FriendRequest
+	if err := database.DB.Where("id = ? AND status = ?", requestID, models.FriendRequestPending).
+		Where(column+" = ?", userID).
+		Where("sender_id NOT IN (?)", blockedIDs(userID)).
+		First(&request).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Friend request not found"})
+		return nil, false
+	}
+
+	return &request, true
+}
+
+// acceptFriendRequest marks a request accepted and notifies its sender
+func acceptFriendRequest(c *gin.Context, request *models.FriendRequest) {
+	if err := database.DB.Model(request).Update("status", models.FriendRequestAccepted).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to accept friend request"})
+		return
+	}
+
+	database.DB.Preload("Sender").Preload("Receiver").First(request, request.ID)
+	websocket.SendToUser(request.SenderID, "friend_request_accepted", friendRequestView(*request))
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Friend request accepted",
+		"request": friendRequestView(*request),
+	})
+}
+
+// friendRequestView returns a friend request with only the public fields of
+// whichever of its users are loaded
+func friendRequestView(request models.FriendRequest) gin.H {
+	view := gin.H{
+		"id":          request.ID,
+		"sender_id":   request.SenderID,
+		"receiver_id": request.ReceiverID,
+		"status":      request.Status,
+		"created_at":  request.CreatedAt,
+		"updated_at":  request.UpdatedAt,
+	}
+	if request.Sender.ID != 0 {
+		view["sender"] = publicUser(request.Sender)
+	}
+	if request.Receiver.ID != 0 {
+		view["receiver"] = publicUser(request.Receiver)
+	}
+	return view
+}
+
+// friendIDs returns a subquery selecting the IDs of the user's friends
+func friendIDs(userID uint) *gorm.DB {
+	return database.DB.Model(&models.FriendRequest{}).
+		Select("CASE WHEN sender_id = ? THEN receiver_id ELSE sender_id END", userID).
+		Where("status = ? AND (sender_id = ? OR receiver_id = ?)", models.FriendRequestAccepted, userID, userID)
+}
+
+// areFriends reports whether two users are friends
+func areFriends(a, b uint) bool {
+	var count int64
+	database.DB.Model(&models.FriendRequest{}).
+		Where("status = ?", models.FriendRequestAccepted).
+		Where("(sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ?)", a, b, b, a).
+		Count(&count)
+	return count > 0
+}
+
+// acceptsInvitesFrom reports whether target's privacy settings let actor add them to rooms
+func acceptsInvitesFrom(targetID, actorID uint) bool {
+	preferences, err := loadPreferences(targetID)
+	if err != nil {
+		return false
+	}
+	return !preferences.FriendsOnly || areFriends(targetID, actorID)
+}
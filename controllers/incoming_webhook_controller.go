@@ -0,0 +1,253 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CUknot/network_backend/database"
+	"github.com/CUknot/network_backend/messaging"
+	"github.com/CUknot/network_backend/models"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"gorm.io/gorm"
+)
+
+// Most attachments accepted on a single incoming webhook message
+const maxIncomingAttachments = 20
+
+type CreateIncomingWebhookInput struct {
+	Name string `json:"name" binding:"required,max=80"`
+}
+
+// IncomingWebhookPayload is the Slack-compatible body posted to an incoming webhook
+type IncomingWebhookPayload struct {
+	Text        string              `json:"text"`
+	Username    string              `json:"username" binding:"max=80"`
+	Attachments []models.Attachment `json:"attachments"`
+}
+
+// GetIncomingWebhooks returns the incoming webhooks of a room
+func GetIncomingWebhooks(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+	roomID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid room ID"})
+		return
+	}
+
+	if !isRoomAdmin(uint(roomID), userID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only room admins can manage webhooks"})
+		return
+	}
+
+	var hooks []models.IncomingWebhook
+	if err := database.DB.Where("room_id = ?", roomID).Preload("User").Order("id ASC").Find(&hooks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch webhooks"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"webhooks": hooks})
+}
+
+// CreateIncomingWebhook creates an incoming webhook and the bot user its messages
+// are attributed to. The token is only ever returned here.
+func CreateIncomingWebhook(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+	roomID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid room ID"})
+		return
+	}
+
+	if !isRoomAdmin(uint(roomID), userID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only room admins can manage webhooks"})
+		return
+	}
+
+	var input CreateIncomingWebhookInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, err := newWebhookSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+		return
+	}
+	bot, err := createBotUser(input.Name)
+	if err != nil {
+		if err == errNoFreeTags {
+			c.JSON(http.StatusConflict, gin.H{"error": "No tags are left for this name, please choose another"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+		return
+	}
+
+	hook := models.IncomingWebhook{
+		RoomID:    uint(roomID),
+		Name:      input.Name,
+		TokenHash: hashToken(token),
+		UserID:    bot.ID,
+		User:      bot,
+		CreatedBy: userID,
+	}
+	if err := database.DB.Omit("User").Create(&hook).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Webhook created successfully",
+		"webhook": hook,
+		"token":   token,
+		"url":     "/api/hooks/" + token,
+	})
+}
+
+// DeleteIncomingWebhook revokes an incoming webhook. Its bot user is kept as a
+// deleted account so earlier messages still have an author.
+func DeleteIncomingWebhook(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+	roomID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid room ID"})
+		return
+	}
+
+	if !isRoomAdmin(uint(roomID), userID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only room admins can manage webhooks"})
+		return
+	}
+
+	webhookID, err := strconv.ParseUint(c.Param("webhookId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+		return
+	}
+
+	var hook models.IncomingWebhook
+	if err := database.DB.Where("id = ? AND room_id = ?", webhookID, roomID).First(&hook).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&hook).Error; err != nil {
+			return err
+		}
+		return retireBotUsers(tx, []uint{hook.UserID})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete webhook"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted successfully"})
+}
+
+// PostIncomingWebhook posts a message into a room on behalf of an incoming webhook.
+// The body is a Slack-style JSON payload, sent either as JSON or as a form-encoded payload field.
+func PostIncomingWebhook(c *gin.Context) {
+	var hook models.IncomingWebhook
+	if err := database.DB.Where("token_hash = ?", hashToken(c.Param("token"))).First(&hook).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	var payload IncomingWebhookPayload
+	if c.ContentType() == "application/x-www-form-urlencoded" {
+		if err := json.Unmarshal([]byte(c.PostForm("payload")), &payload); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "payload must be a JSON object"})
+			return
+		}
+	} else if err := json.NewDecoder(c.Request.Body).Decode(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := binding.Validator.ValidateStruct(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(payload.Attachments) > maxIncomingAttachments {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d attachments are allowed", maxIncomingAttachments)})
+		return
+	}
+	payload.Attachments = messaging.SanitizeAttachments(payload.Attachments)
+
+	// Attachment-only messages still need some text for notifications and search
+	content := strings.TrimSpace(payload.Text)
+	for _, attachment := range payload.Attachments {
+		if content != "" {
+			break
+		}
+		for _, text := range []string{attachment.Fallback, attachment.Pretext, attachment.Title, attachment.Text} {
+			if text = strings.TrimSpace(text); text != "" {
+				content = text
+				break
+			}
+		}
+	}
+	if content == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "text or attachments are required"})
+		return
+	}
+
+	message := models.Message{
+		Content:     content,
+		RoomID:      hook.RoomID,
+		UserID:      hook.UserID,
+		SenderName:  payload.Username,
+		Attachments: payload.Attachments,
+	}
+
+	// Save, broadcast, and notify mentioned users just like CreateMessage
+	if err := messaging.Post(&message); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create message"})
+		return
+	}
+
+	database.DB.Model(&hook).Update("last_used_at", time.Now())
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Message sent successfully",
+		"data":    message,
+	})
+}
+
+// createBotUser creates the pseudo-user that messages from webhooks and bots are
+// attributed to. Bots never log in, so they get an address and password nobody knows.
+func createBotUser(name string) (models.User, error) {
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return models.User{}, err
+	}
+	// The address is public, so it comes from its own random value rather than the password
+	mailbox, err := newWebhookSecret()
+	if err != nil {
+		return models.User{}, err
+	}
+
+	bot := models.User{
+		Username: name,
+		Email:    fmt.Sprintf("bot-%s@bots.invalid", mailbox[:16]),
+		Password: secret,
+		IsBot:    true,
+	}
+	err = createUserWithFreeTag(&bot)
+	return bot, err
+}
+
+// retireBotUsers marks bot users as deleted once nothing posts as them any more
+func retireBotUsers(tx *gorm.DB, userIDs []uint) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	return tx.Model(&models.User{}).Where("id IN ? AND is_bot", userIDs).Update("deleted_at", time.Now()).Error
+}
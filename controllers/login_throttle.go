@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/CUknot/network_backend/audit"
+	"github.com/CUknot/network_backend/database"
+	"github.com/CUknot/network_backend/models"
+	"github.com/CUknot/network_backend/throttle"
+)
+
+// Limits for failed logins against a single account
+var accountLoginPolicy = throttle.Policy{
+	FreeAttempts:     3,
+	BaseDelay:        2 * time.Second,
+	MaxDelay:         time.Minute,
+	LockoutThreshold: 10,
+	LockoutDuration:  15 * time.Minute,
+	ResetAfter:       time.Hour,
+}
+
+// Limits for failed logins from a single IP address across all accounts
+var ipLoginPolicy = throttle.Policy{
+	FreeAttempts:     10,
+	BaseDelay:        time.Second,
+	MaxDelay:         time.Minute,
+	LockoutThreshold: 50,
+	LockoutDuration:  15 * time.Minute,
+	ResetAfter:       time.Hour,
+}
+
+var (
+	accountLoginLimiter *throttle.Limiter
+	ipLoginLimiter      *throttle.Limiter
+)
+
+// InitLoginThrottle sets up failed-login tracking using the store selected by LOGIN_THROTTLE_STORE
+func InitLoginThrottle() {
+	var store throttle.Store
+	switch os.Getenv("LOGIN_THROTTLE_STORE") {
+	case "memory":
+		store = throttle.NewMemoryStore()
+	default:
+		store = throttle.NewPostgresStore(database.DB)
+	}
+
+	accountLoginLimiter = throttle.NewLimiter(store, accountLoginPolicy, "account:")
+	ipLoginLimiter = throttle.NewLimiter(store, ipLoginPolicy, "ip:")
+}
+
+// loginAttempt is a login counted as failed against its account and client
+// address before the password is checked
+type loginAttempt struct {
+	email   string
+	ip      string
+	counted []countedFailure
+}
+
+// countedFailure is a failure a login attempt recorded in one limiter
+type countedFailure struct {
+	limiter *throttle.Limiter
+	key     string
+	locked  bool
+}
+
+// startLoginAttempt counts a login as failed until it succeeds, unless the
+// account or client address is throttled, in which case it returns how long
+// to wait in whole seconds
+func startLoginAttempt(email, ip string) (*loginAttempt, int) {
+	now := time.Now()
+	attempt := &loginAttempt{email: strings.ToLower(email), ip: ip}
+
+	for _, check := range []countedFailure{
+		{limiter: accountLoginLimiter, key: attempt.email},
+		{limiter: ipLoginLimiter, key: ip},
+	} {
+		// Fail open so a storage outage does not lock everyone out
+		wait, locked, err := check.limiter.Attempt(check.key, now)
+		if err != nil {
+			log.Printf("error checking login throttle: %v", err)
+			continue
+		}
+		if wait > 0 {
+			// The attempt never happens, so it doesn't count anywhere
+			for _, failure := range attempt.counted {
+				forgiveLoginFailure(failure)
+			}
+			return nil, int(math.Ceil(wait.Seconds()))
+		}
+		check.locked = locked
+		attempt.counted = append(attempt.counted, check)
+	}
+
+	return attempt, 0
+}
+
+// failed audits any lockout the failed attempt caused
+func (a *loginAttempt) failed(userID *uint) {
+	for _, failure := range a.counted {
+		if !failure.locked {
+			continue
+		}
+		if failure.limiter == accountLoginLimiter {
+			audit.Record(models.AuditLog{
+				Action:    audit.ActionLoginLockout,
+				UserID:    userID,
+				IPAddress: a.ip,
+				Details:   fmt.Sprintf("account %s locked for %s", a.email, accountLoginPolicy.LockoutDuration),
+			})
+		} else {
+			audit.Record(models.AuditLog{
+				Action:    audit.ActionLoginLockout,
+				IPAddress: a.ip,
+				Details:   fmt.Sprintf("ip %s locked for %s", a.ip, ipLoginPolicy.LockoutDuration),
+			})
+		}
+	}
+}
+
+// succeeded clears the account's failure history and takes back the failure
+// counted against the client address
+func (a *loginAttempt) succeeded() {
+	if err := accountLoginLimiter.Reset(a.email); err != nil {
+		log.Printf("error resetting login throttle: %v", err)
+	}
+	for _, failure := range a.counted {
+		if failure.limiter == ipLoginLimiter {
+			forgiveLoginFailure(failure)
+		}
+	}
+}
+
+// forgiveLoginFailure takes back a failure counted for an attempt
+func forgiveLoginFailure(failure countedFailure) {
+	if err := failure.limiter.Forgive(failure.key); err != nil {
+		log.Printf("error updating login throttle: %v", err)
+	}
+}
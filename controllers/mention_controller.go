@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/CUknot/network_backend/database"
+	"github.com/CUknot/network_backend/messaging"
+	"github.com/CUknot/network_backend/models"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// Default and maximum page sizes for list endpoints
+	defaultPageSize = 50
+	maxPageSize     = 100
+)
+
+type MarkMentionsReadInput struct {
+	IDs []uint `json:"ids"`
+}
+
+// GetMentions returns the authenticated user's mentions, newest first
+func GetMentions(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	limit := pageSize(c)
+	query := database.DB.Where("user_id = ?", userID).
+		Where("room_id IN (?)", database.DB.Model(&models.RoomUser{}).Select("room_id").Where("user_id = ?", userID))
+
+	if c.Query("unread") == "true" {
+		query = query.Where("read_at IS NULL")
+	}
+	if before, err := strconv.ParseUint(c.Query("before"), 10, 32); err == nil {
+		query = query.Where("id < ?", before)
+	}
+
+	var mentions []models.Mention
+	if err := query.Order("id DESC").
+		Limit(limit).
+		Preload("Message").
+		Preload("Message.User").
+		Find(&mentions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch mentions"})
+		return
+	}
+
+	// Quotes from rooms the user isn't in are left out
+	messages := make([]*models.Message, len(mentions))
+	for i := range mentions {
+		messages[i] = mentions[i].Message
+	}
+	if err := messaging.HideQuotes(messages, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch mentions"})
+		return
+	}
+
+	var unread int64
+	database.DB.Model(&models.Mention{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Where("room_id IN (?)", database.DB.Model(&models.RoomUser{}).Select("room_id").Where("user_id = ?", userID)).
+		Count(&unread)
+
+	c.JSON(http.StatusOK, gin.H{
+		"mentions":     mentions,
+		"unread_count": unread,
+	})
+}
+
+// MarkMentionsRead marks the given mentions, or all mentions when none are given, as read
+func MarkMentionsRead(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	// An empty body marks everything as read
+	var input MarkMentionsReadInput
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := database.DB.Model(&models.Mention{}).Where("user_id = ? AND read_at IS NULL", userID)
+	if len(input.IDs) > 0 {
+		query = query.Where("id IN ?", input.IDs)
+	}
+
+	if err := query.Update("read_at", time.Now()).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark mentions as read"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Mentions marked as read"})
+}
+
+// pageSize reads the limit query parameter, clamped to the allowed range
+func pageSize(c *gin.Context) int {
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit <= 0 {
+		return defaultPageSize
+	}
+	if limit > maxPageSize {
+		return maxPageSize
+	}
+	return limit
+}
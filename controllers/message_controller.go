@@ -1,18 +1,38 @@
 package controllers
 
 import (
+	"errors"
+	"log"
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/CUknot/network_backend/commands"
 	"github.com/CUknot/network_backend/database"
+	"github.com/CUknot/network_backend/messaging"
 	"github.com/CUknot/network_backend/models"
 	"github.com/CUknot/network_backend/websocket"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
+// Unique index that makes client_msg_id retries idempotent
+const clientMsgIndex = "idx_client_msg"
+
 type CreateMessageInput struct {
+	Content         string     `json:"content" binding:"required"`
+	RoomID          uint       `json:"room_id" binding:"required"`
+	SendAt          *time.Time `json:"send_at"`
+	ClientMsgID     string     `json:"client_msg_id" binding:"max=64"`
+	QuotedMessageID *uint      `json:"quoted_message_id"`
+}
+
+type UpdateMessageInput struct {
 	Content string `json:"content" binding:"required"`
-	RoomID  uint   `json:"room_id" binding:"required"`
+}
+
+type ForwardMessageInput struct {
+	RoomIDs []uint `json:"room_ids" binding:"required,min=1,max=10"`
 }
 
 // GetMessages returns all messages for a specific room
@@ -32,14 +52,41 @@ func GetMessages(c *gin.Context) {
 	}
 
 	var messages []models.Message
+	// Messages from blocked users are hidden, and only the viewer's own mentions
+	// are included so nobody sees when others read theirs
 	if err := database.DB.Where("room_id = ?", roomID).
+		Where("user_id NOT IN (?)", blockedIDs(userID)).
 		Order("created_at ASC").
 		Preload("User").
+		Preload("Mentions", "user_id = ?", userID).
+		Preload("Poll.Options", messaging.PollOptionOrder).
 		Find(&messages).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch messages"})
 		return
 	}
 
+	// Polls come with their current results
+	var polls []*models.Poll
+	for i := range messages {
+		if messages[i].Poll != nil {
+			polls = append(polls, messages[i].Poll)
+		}
+	}
+	if err := loadPollResults(polls, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch poll results"})
+		return
+	}
+
+	// Quotes from rooms the user isn't in are left out
+	views := make([]*models.Message, len(messages))
+	for i := range messages {
+		views[i] = &messages[i]
+	}
+	if err := messaging.HideQuotes(views, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch messages"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{"messages": messages})
 }
 
@@ -60,26 +107,327 @@ func CreateMessage(c *gin.Context) {
 		return
 	}
 
+	// A quoted message must be one the user can see
+	var quote *models.Quote
+	if input.QuotedMessageID != nil {
+		quoted, ok := findVisibleMessage(*input.QuotedMessageID, userID)
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Quoted message not found"})
+			return
+		}
+		quote = messaging.NewQuote(quoted)
+	}
+
+	// Messages due in the future are queued for the scheduler
+	if input.SendAt != nil && input.SendAt.After(time.Now()) {
+		if quote != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Messages with quotes can't be scheduled"})
+			return
+		}
+		scheduleMessage(c, userID, input)
+		return
+	}
+
+	// Slash commands are run instead of being stored as text
+	if name, args, ok := commands.Parse(input.Content); ok {
+		response, err := commands.Execute(commands.Context{UserID: userID, RoomID: input.RoomID, Name: name, Args: args})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to run command"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":   "Command executed successfully",
+			"command":   name,
+			"ephemeral": response.Ephemeral,
+			"data":      response.Message,
+		})
+		return
+	}
+
 	// Create message
 	message := models.Message{
-		Content: input.Content,
-		RoomID:  input.RoomID,
-		UserID:  userID,
+		Content:         commands.Unescape(input.Content),
+		RoomID:          input.RoomID,
+		UserID:          userID,
+		ClientMsgID:     clientMsgID(input.ClientMsgID),
+		QuotedMessageID: input.QuotedMessageID,
+		Quote:           quote,
 	}
 
-	if err := database.DB.Create(&message).Error; err != nil {
+	// Save, broadcast, and notify mentioned users
+	duplicate, err := postMessage(&message)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create message"})
 		return
 	}
 
-	// Load user data for the message
-	database.DB.Preload("User").First(&message, message.ID)
-
-	// Broadcast message to room
-	websocket.BroadcastToRoom(input.RoomID, "message", message)
+	// A retry gets the original message back
+	if duplicate {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Message already sent",
+			"data":    message,
+		})
+		return
+	}
 
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "Message sent successfully",
 		"data":    message,
 	})
 }
+
+// HandleSocketMessage sends a chat message received over a websocket connection,
+// running it as a slash command if it is one
+func HandleSocketMessage(userID, roomID uint, content, clientMsg string) error {
+	// Check if user is a member of the room
+	var roomUser models.RoomUser
+	if err := database.DB.Where("room_id = ? AND user_id = ?", roomID, userID).First(&roomUser).Error; err != nil {
+		return errors.New("you don't have access to this room")
+	}
+
+	if name, args, ok := commands.Parse(content); ok {
+		if _, err := commands.Execute(commands.Context{UserID: userID, RoomID: roomID, Name: name, Args: args}); err != nil {
+			log.Printf("error running /%s in room %d: %v", name, roomID, err)
+			return errors.New("failed to run command")
+		}
+		return nil
+	}
+
+	message := models.Message{
+		Content:     commands.Unescape(content),
+		RoomID:      roomID,
+		UserID:      userID,
+		ClientMsgID: clientMsgID(clientMsg),
+	}
+	duplicate, err := postMessage(&message)
+	if err != nil {
+		log.Printf("error posting message to room %d: %v", roomID, err)
+		return errors.New("failed to create message")
+	}
+
+	// Let the sender reconcile a retried message with the original
+	if duplicate {
+		websocket.SendToUser(userID, "message", message)
+	}
+	return nil
+}
+
+// postMessage saves and publishes a message. If the message's client_msg_id was
+// already used in the room, the original is loaded into message instead and
+// duplicate is true.
+func postMessage(message *models.Message) (bool, error) {
+	if message.ClientMsgID != nil && findClientMessage(message) {
+		return true, nil
+	}
+
+	err := messaging.Post(message)
+	if message.ClientMsgID != nil && database.IsUniqueViolation(err, clientMsgIndex) {
+		// A concurrent retry got there first
+		if findClientMessage(message) {
+			return true, nil
+		}
+	}
+	return false, err
+}
+
+// findClientMessage loads the message previously sent with message's client_msg_id
+func findClientMessage(message *models.Message) bool {
+	var original models.Message
+	if err := database.DB.Where("user_id = ? AND room_id = ? AND client_msg_id = ?", message.UserID, message.RoomID, *message.ClientMsgID).
+		Preload("User").
+		Preload("Mentions", "user_id = ?", message.UserID).
+		First(&original).Error; err != nil {
+		return false
+	}
+	*message = original
+	return true
+}
+
+// findVisibleMessage loads a message if the user belongs to its room and hasn't blocked its author
+func findVisibleMessage(messageID, userID uint) (models.Message, bool) {
+	var message models.Message
+	if err := database.DB.Where("id = ?", messageID).
+		Where("room_id IN (?)", database.DB.Model(&models.RoomUser{}).Select("room_id").Where("user_id = ?", userID)).
+		Where("user_id NOT IN (?)", blockedIDs(userID)).
+		Preload("User").
+		First(&message).Error; err != nil {
+		return message, false
+	}
+	return message, true
+}
+
+// clientMsgID turns an optional client_msg_id into the value stored on a message
+func clientMsgID(id string) *string {
+	if id == "" {
+		return nil
+	}
+	return &id
+}
+
+// UpdateMessage edits the content of one of the authenticated user's messages
+func UpdateMessage(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	messageID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid message ID"})
+		return
+	}
+
+	var message models.Message
+	if err := database.DB.First(&message, uint(messageID)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return
+	}
+
+	if message.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only edit your own messages"})
+		return
+	}
+
+	// Changing a poll's question would change what people voted on
+	if message.Type == models.MessagePoll {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Polls can't be edited"})
+		return
+	}
+
+	// Former members can no longer change what they said
+	var roomUser models.RoomUser
+	if err := database.DB.Where("room_id = ? AND user_id = ?", message.RoomID, userID).First(&roomUser).Error; err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have access to this room"})
+		return
+	}
+
+	var input UpdateMessageInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := messaging.Edit(&message, input.Content); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update message"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Message updated successfully",
+		"data":    message,
+	})
+}
+
+// DeleteMessage deletes a message. Authors can delete their own messages and room admins any message in the room.
+func DeleteMessage(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	messageID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid message ID"})
+		return
+	}
+
+	var message models.Message
+	if err := database.DB.First(&message, uint(messageID)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return
+	}
+
+	if !isRoomAdmin(message.RoomID, userID) {
+		var roomUser models.RoomUser
+		if message.UserID != userID ||
+			database.DB.Where("room_id = ? AND user_id = ?", message.RoomID, userID).First(&roomUser).Error != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You can't delete this message"})
+			return
+		}
+	}
+
+	if err := messaging.Delete([]models.Message{message}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete message"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Message deleted successfully"})
+}
+
+// ForwardMessage copies a message and its attachments into other rooms the authenticated user belongs to
+func ForwardMessage(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+	messageID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid message ID"})
+		return
+	}
+
+	var input ForwardMessageInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	original, ok := findVisibleMessage(uint(messageID), userID)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return
+	}
+
+	// A poll's votes belong to the room it was posted in
+	if original.Type == models.MessagePoll {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Polls can't be forwarded"})
+		return
+	}
+
+	// Check if user is a member of every room
+	roomIDs := make([]uint, 0, len(input.RoomIDs))
+	seen := make(map[uint]bool, len(input.RoomIDs))
+	for _, id := range input.RoomIDs {
+		if !seen[id] {
+			seen[id] = true
+			roomIDs = append(roomIDs, id)
+		}
+	}
+	var count int64
+	if err := database.DB.Model(&models.RoomUser{}).Where("user_id = ? AND room_id IN ?", userID, roomIDs).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to forward message"})
+		return
+	}
+	if count != int64(len(roomIDs)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have access to this room"})
+		return
+	}
+
+	// Forwarding a forward still points at the message first sent
+	forwardedFrom := original.ID
+	if original.ForwardedFromID != nil {
+		forwardedFrom = *original.ForwardedFromID
+	}
+
+	messages := make([]models.Message, len(roomIDs))
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		for i, roomID := range roomIDs {
+			messages[i] = models.Message{
+				Content:         original.Content,
+				RoomID:          roomID,
+				UserID:          userID,
+				Attachments:     original.Attachments,
+				ForwardedFromID: &forwardedFrom,
+			}
+			if err := messaging.Save(tx, &messages[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to forward message"})
+		return
+	}
+
+	for i := range messages {
+		messaging.Publish(&messages[i])
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Message forwarded successfully",
+		"data":    messages,
+	})
+}
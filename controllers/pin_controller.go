@@ -0,0 +1,180 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/CUknot/network_backend/database"
+	"github.com/CUknot/network_backend/messaging"
+	"github.com/CUknot/network_backend/models"
+	"github.com/CUknot/network_backend/websocket"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Most messages that can be pinned in a room at once
+const maxPinsPerRoom = 50
+
+var errTooManyPins = fmt.Errorf("a room can have at most %d pinned messages", maxPinsPerRoom)
+
+type PinMessageInput struct {
+	MessageID uint `json:"message_id" binding:"required"`
+}
+
+// GetPins returns the pinned messages of a room, most recently pinned first
+func GetPins(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+	roomID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid room ID"})
+		return
+	}
+
+	// Check if user is a member of the room
+	var roomUser models.RoomUser
+	if err := database.DB.Where("room_id = ? AND user_id = ?", roomID, userID).First(&roomUser).Error; err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have access to this room"})
+		return
+	}
+
+	// Pinned messages from blocked users are hidden like any other of their messages
+	var pins []models.Pin
+	if err := database.DB.Joins("JOIN messages ON messages.id = pins.message_id").
+		Where("pins.room_id = ? AND messages.user_id NOT IN (?)", roomID, blockedIDs(userID)).
+		Preload("Message").
+		Preload("Message.User").
+		Preload("PinnedByUser").
+		Order("pins.created_at DESC").
+		Find(&pins).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch pins"})
+		return
+	}
+
+	// Quotes from rooms the user isn't in are left out
+	messages := make([]*models.Message, len(pins))
+	for i := range pins {
+		messages[i] = pins[i].Message
+	}
+	if err := messaging.HideQuotes(messages, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch pins"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"pins": pins})
+}
+
+// PinMessage pins a message in a room
+func PinMessage(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+	roomID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid room ID"})
+		return
+	}
+
+	if !isRoomAdmin(uint(roomID), userID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only room admins can pin messages"})
+		return
+	}
+
+	var input PinMessageInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var message models.Message
+	if err := database.DB.Where("id = ? AND room_id = ?", input.MessageID, roomID).First(&message).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return
+	}
+
+	pin := models.Pin{
+		RoomID:    uint(roomID),
+		MessageID: message.ID,
+		PinnedBy:  userID,
+	}
+	var created bool
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// Lock the room so concurrent pins can't go over the cap
+		var room models.Room
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&room, roomID).Error; err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&models.Pin{}).Where("room_id = ?", roomID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count >= maxPinsPerRoom {
+			return errTooManyPins
+		}
+
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&pin)
+		created = result.RowsAffected > 0
+		return result.Error
+	})
+	if errors.Is(err, errTooManyPins) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Unpin a message first, " + err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to pin message"})
+		return
+	}
+	if !created {
+		c.JSON(http.StatusConflict, gin.H{"error": "Message is already pinned"})
+		return
+	}
+
+	database.DB.Preload("Message").Preload("Message.User").Preload("PinnedByUser").First(&pin, pin.ID)
+	messaging.DropForeignQuote(pin.Message)
+	websocket.BroadcastToRoomFrom(pin.RoomID, message.UserID, "message_pinned", pin)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Message pinned successfully",
+		"pin":     pin,
+	})
+}
+
+// UnpinMessage removes a pinned message from a room
+func UnpinMessage(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+	roomID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid room ID"})
+		return
+	}
+
+	messageID, err := strconv.ParseUint(c.Param("messageId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid message ID"})
+		return
+	}
+
+	if !isRoomAdmin(uint(roomID), userID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only room admins can unpin messages"})
+		return
+	}
+
+	result := database.DB.Where("room_id = ? AND message_id = ?", roomID, messageID).Delete(&models.Pin{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unpin message"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message is not pinned"})
+		return
+	}
+
+	websocket.BroadcastToRoom(uint(roomID), "message_unpinned", gin.H{
+		"room_id":     roomID,
+		"message_id":  messageID,
+		"unpinned_by": userID,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Message unpinned successfully"})
+}
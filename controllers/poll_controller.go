@@ -0,0 +1,353 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CUknot/network_backend/database"
+	"github.com/CUknot/network_backend/messaging"
+	"github.com/CUknot/network_backend/models"
+	"github.com/CUknot/network_backend/websocket"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errNotRoomMember = errors.New("not a member of the room")
+
+type CreatePollInput struct {
+	RoomID         uint       `json:"room_id" binding:"required"`
+	Question       string     `json:"question" binding:"required,max=300"`
+	Options        []string   `json:"options" binding:"required,min=2,max=10,dive,required,max=200"`
+	MultipleChoice bool       `json:"multiple_choice"`
+	Anonymous      bool       `json:"anonymous"`
+	ClosesAt       *time.Time `json:"closes_at"`
+}
+
+type VoteInput struct {
+	OptionIDs []uint `json:"option_ids" binding:"required,min=1"`
+}
+
+// CreatePoll posts a poll message to a room
+func CreatePoll(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	var input CreatePollInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check if user is a member of the room
+	var roomUser models.RoomUser
+	if err := database.DB.Where("room_id = ? AND user_id = ?", input.RoomID, userID).First(&roomUser).Error; err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have access to this room"})
+		return
+	}
+
+	if input.ClosesAt != nil && !input.ClosesAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Close time must be in the future"})
+		return
+	}
+
+	// Options are shown in the order given and must be distinct
+	options := make([]models.PollOption, 0, len(input.Options))
+	seen := make(map[string]bool, len(input.Options))
+	for i, text := range input.Options {
+		text = strings.TrimSpace(text)
+		if text == "" || seen[strings.ToLower(text)] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Poll options must be distinct and non-empty"})
+			return
+		}
+		seen[strings.ToLower(text)] = true
+		options = append(options, models.PollOption{Position: i, Text: text})
+	}
+
+	// The question is the message's content, so it shows up in notifications and search
+	message := models.Message{
+		Type:    models.MessagePoll,
+		Content: input.Question,
+		RoomID:  input.RoomID,
+		UserID:  userID,
+		Poll: &models.Poll{
+			RoomID:         input.RoomID,
+			MultipleChoice: input.MultipleChoice,
+			Anonymous:      input.Anonymous,
+			ClosesAt:       input.ClosesAt,
+			CreatedBy:      userID,
+			Options:        options,
+		},
+	}
+
+	// Saving the message creates the poll and its options with it
+	if err := messaging.Post(&message); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create poll"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Poll created successfully",
+		"data":    message,
+	})
+}
+
+// VotePoll sets the authenticated user's votes in a poll, replacing any they cast before
+func VotePoll(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	pollID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid poll ID"})
+		return
+	}
+
+	var poll models.Poll
+	if err := database.DB.Preload("Options", messaging.PollOptionOrder).First(&poll, uint(pollID)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Poll not found"})
+		return
+	}
+
+	var input VoteInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Every choice must be one of the poll's options
+	valid := make(map[uint]bool, len(poll.Options))
+	for _, option := range poll.Options {
+		valid[option.ID] = true
+	}
+	chosen := make(map[uint]bool, len(input.OptionIDs))
+	var votes []models.PollVote
+	for _, id := range input.OptionIDs {
+		if !valid[id] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid poll option"})
+			return
+		}
+		if chosen[id] {
+			continue
+		}
+		chosen[id] = true
+		votes = append(votes, models.PollVote{PollID: poll.ID, UserID: userID, OptionID: id})
+	}
+	if !poll.MultipleChoice && len(votes) > 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This poll allows only one choice"})
+		return
+	}
+
+	if poll.Closed() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Poll is closed"})
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockPollVoter(tx, poll.RoomID, userID); err != nil {
+			return err
+		}
+		if err := tx.Where("poll_id = ? AND user_id = ?", poll.ID, userID).Delete(&models.PollVote{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&votes).Error
+	})
+	if errors.Is(err, errNotRoomMember) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have access to this room"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record vote"})
+		return
+	}
+
+	if err := broadcastPoll(&poll); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch poll results"})
+		return
+	}
+	poll.MyVotes = make([]uint, 0, len(votes))
+	for _, vote := range votes {
+		poll.MyVotes = append(poll.MyVotes, vote.OptionID)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Vote recorded successfully",
+		"poll":    poll,
+	})
+}
+
+// RetractVote removes the authenticated user's votes from a poll
+func RetractVote(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	pollID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid poll ID"})
+		return
+	}
+
+	var poll models.Poll
+	if err := database.DB.Preload("Options", messaging.PollOptionOrder).First(&poll, uint(pollID)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Poll not found"})
+		return
+	}
+
+	if poll.Closed() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Poll is closed"})
+		return
+	}
+
+	var retracted int64
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockPollVoter(tx, poll.RoomID, userID); err != nil {
+			return err
+		}
+		result := tx.Where("poll_id = ? AND user_id = ?", poll.ID, userID).Delete(&models.PollVote{})
+		retracted = result.RowsAffected
+		return result.Error
+	})
+	if errors.Is(err, errNotRoomMember) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have access to this room"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retract vote"})
+		return
+	}
+
+	if retracted > 0 {
+		if err := broadcastPoll(&poll); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch poll results"})
+			return
+		}
+	} else if err := loadPollResults([]*models.Poll{&poll}, 0); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch poll results"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Vote retracted successfully",
+		"poll":    poll,
+	})
+}
+
+// ClosePoll stops a poll from accepting votes. The poll's creator and room admins can close it.
+func ClosePoll(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	pollID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid poll ID"})
+		return
+	}
+
+	var poll models.Poll
+	if err := database.DB.Preload("Options", messaging.PollOptionOrder).First(&poll, uint(pollID)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Poll not found"})
+		return
+	}
+
+	if !isRoomAdmin(poll.RoomID, userID) {
+		var roomUser models.RoomUser
+		if poll.CreatedBy != userID ||
+			database.DB.Where("room_id = ? AND user_id = ?", poll.RoomID, userID).First(&roomUser).Error != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You can't close this poll"})
+			return
+		}
+	}
+
+	// Only the first close counts so the close time isn't moved
+	now := time.Now()
+	result := database.DB.Model(&models.Poll{}).
+		Where("id = ? AND (closes_at IS NULL OR closes_at > ?)", poll.ID, now).
+		Update("closes_at", now)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to close poll"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Poll is already closed"})
+		return
+	}
+	poll.ClosesAt = &now
+
+	if err := broadcastPoll(&poll); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch poll results"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Poll closed successfully",
+		"poll":    poll,
+	})
+}
+
+// lockPollVoter locks the voter's room membership so their concurrent votes are
+// applied one at a time, and fails with errNotRoomMember if they are not a member
+func lockPollVoter(tx *gorm.DB, roomID, userID uint) error {
+	var roomUser models.RoomUser
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("room_id = ? AND user_id = ?", roomID, userID).
+		First(&roomUser).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return errNotRoomMember
+	}
+	return err
+}
+
+// broadcastPoll loads a poll's current results and sends them to its room
+func broadcastPoll(poll *models.Poll) error {
+	if err := loadPollResults([]*models.Poll{poll}, 0); err != nil {
+		return err
+	}
+	websocket.BroadcastToRoom(poll.RoomID, "poll_updated", poll)
+	return nil
+}
+
+// loadPollResults fills in the vote counts of polls, the voters of polls that
+// aren't anonymous, and the options the viewer voted for
+func loadPollResults(polls []*models.Poll, viewerID uint) error {
+	if len(polls) == 0 {
+		return nil
+	}
+
+	byID := make(map[uint]*models.Poll, len(polls))
+	ids := make([]uint, 0, len(polls))
+	for _, poll := range polls {
+		byID[poll.ID] = poll
+		ids = append(ids, poll.ID)
+	}
+
+	var votes []models.PollVote
+	if err := database.DB.Where("poll_id IN ?", ids).Order("created_at ASC").Find(&votes).Error; err != nil {
+		return err
+	}
+
+	voters := make(map[uint]map[uint]bool, len(polls))
+	for _, vote := range votes {
+		poll := byID[vote.PollID]
+		if voters[poll.ID] == nil {
+			voters[poll.ID] = make(map[uint]bool)
+		}
+		voters[poll.ID][vote.UserID] = true
+
+		for i := range poll.Options {
+			option := &poll.Options[i]
+			if option.ID != vote.OptionID {
+				continue
+			}
+			option.Votes++
+			if !poll.Anonymous {
+				option.Voters = append(option.Voters, vote.UserID)
+			}
+		}
+		if viewerID != 0 && vote.UserID == viewerID {
+			poll.MyVotes = append(poll.MyVotes, vote.OptionID)
+		}
+	}
+	for _, poll := range polls {
+		poll.TotalVoters = int64(len(voters[poll.ID]))
+	}
+	return nil
+}
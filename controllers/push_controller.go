@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/CUknot/network_backend/database"
+	"github.com/CUknot/network_backend/models"
+	"github.com/CUknot/network_backend/push"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+)
+
+type PushSubscriptionInput struct {
+	Endpoint string `json:"endpoint" binding:"required,url,startswith=https://"`
+	Keys     struct {
+		P256dh string `json:"p256dh" binding:"required"`
+		Auth   string `json:"auth" binding:"required"`
+	} `json:"keys" binding:"required"`
+}
+
+type DeletePushSubscriptionInput struct {
+	Endpoint string `json:"endpoint" binding:"required"`
+}
+
+// GetVapidPublicKey returns the key browsers need to create a push subscription
+func GetVapidPublicKey(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"public_key": push.PublicKey()})
+}
+
+// CreatePushSubscription registers a browser push subscription for the authenticated user
+func CreatePushSubscription(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	var input PushSubscriptionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// A browser re-subscribing keeps its endpoint, so update it in place
+	subscription := models.PushSubscription{
+		UserID:    userID,
+		Endpoint:  input.Endpoint,
+		P256dh:    input.Keys.P256dh,
+		Auth:      input.Keys.Auth,
+		UserAgent: c.Request.UserAgent(),
+	}
+	if err := database.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "endpoint"}},
+		DoUpdates: clause.AssignmentColumns([]string{"user_id", "p256dh", "auth", "user_agent", "updated_at"}),
+	}).Create(&subscription).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save push subscription"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":      "Push subscription saved successfully",
+		"subscription": subscription,
+	})
+}
+
+// DeletePushSubscription removes one of the authenticated user's push subscriptions
+func DeletePushSubscription(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	var input DeletePushSubscriptionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := database.DB.Where("user_id = ? AND endpoint = ?", userID, input.Endpoint).
+		Delete(&models.PushSubscription{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete push subscription"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Push subscription deleted successfully"})
+}
@@ -1,22 +1,59 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/CUknot/network_backend/audit"
 	"github.com/CUknot/network_backend/database"
 	"github.com/CUknot/network_backend/models"
+	"github.com/CUknot/network_backend/retention"
+	"github.com/CUknot/network_backend/webhooks"
+	"github.com/CUknot/network_backend/websocket"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type CreateRoomInput struct {
-	Name    string `json:"name" binding:"required"`
-	UserIDs []uint `json:"user_ids"`
+	Name    string   `json:"name" binding:"required"`
+	UserIDs []uint   `json:"user_ids"`
+	Handles []string `json:"handles"`
 }
 
 type UpdateRoomInput struct {
-	Name    string `json:"name"`
-	UserIDs []uint `json:"user_ids"`
+	Name    string   `json:"name"`
+	UserIDs []uint   `json:"user_ids"`
+	Handles []string `json:"handles"`
+}
+
+type CreateDirectRoomInput struct {
+	UserID uint   `json:"user_id"`
+	Handle string `json:"handle"`
+}
+
+type UpdateRoomNotificationsInput struct {
+	Level     *string    `json:"level" binding:"omitempty,oneof=all mentions none default"`
+	MuteFor   string     `json:"mute_for"`
+	MuteUntil *time.Time `json:"mute_until"`
+	Unmute    bool       `json:"unmute"`
+}
+
+type UpdateRoomRetentionInput struct {
+	MessageTTL string `json:"message_ttl" binding:"required"`
+}
+
+type UpdateMemberRoleInput struct {
+	Role string `json:"role" binding:"required,oneof=admin member"`
+}
+
+type AddRoomMembersInput struct {
+	UserIDs []uint   `json:"user_ids"`
+	Handles []string `json:"handles"`
 }
 
 // GetRooms returns all rooms for the authenticated user
@@ -32,6 +69,25 @@ func GetRooms(c *gin.Context) {
 		return
 	}
 
+	// Attach the user's notification settings for each room
+	var memberships []models.RoomUser
+	if err := database.DB.Where("user_id = ?", userID).Find(&memberships).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch rooms"})
+		return
+	}
+	settings := make(map[uint]*models.RoomNotificationSettings, len(memberships))
+	now := time.Now()
+	for _, membership := range memberships {
+		setting := &models.RoomNotificationSettings{Level: membership.NotificationLevel}
+		if membership.Muted(now) {
+			setting.MutedUntil = membership.MutedUntil
+		}
+		settings[membership.RoomID] = setting
+	}
+	for i := range rooms {
+		rooms[i].Notifications = settings[rooms[i].ID]
+	}
+
 	c.JSON(http.StatusOK, gin.H{"rooms": rooms})
 }
 
@@ -45,6 +101,18 @@ func CreateRoom(c *gin.Context) {
 		return
 	}
 
+	// Resolve members given by handle
+	memberIDs, err := resolveMemberIDs(input.UserIDs, input.Handles)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	memberIDs, err = filterInvitations(userID, memberIDs)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Create room
 	room := models.Room{
 		Name:      input.Name,
@@ -60,6 +128,7 @@ func CreateRoom(c *gin.Context) {
 	roomUser := models.RoomUser{
 		RoomID: room.ID,
 		UserID: userID,
+		Role:   models.RoleOwner,
 	}
 	if err := database.DB.Create(&roomUser).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add user to room"})
@@ -67,7 +136,7 @@ func CreateRoom(c *gin.Context) {
 	}
 
 	// Add other users to room if provided
-	for _, id := range input.UserIDs {
+	for _, id := range memberIDs {
 		if id == userID {
 			continue // Skip creator as they're already added
 		}
@@ -79,12 +148,84 @@ func CreateRoom(c *gin.Context) {
 		database.DB.Create(&roomUser)
 	}
 
+	// Let every member's open connections know about the new room
+	database.DB.Preload("Users").First(&room, room.ID)
+	subscribeRoomMembers(room.ID)
+	notifyRoomMembers(room.ID, "room_created", room)
+
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "Room created successfully",
 		"room":    room,
 	})
 }
 
+// CreateDirectRoom opens the direct message room with another user, creating it if needed
+func CreateDirectRoom(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	var input CreateDirectRoomInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	targetID, err := resolveUser(input.UserID, input.Handle)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if targetID == userID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You can't message yourself"})
+		return
+	}
+
+	// Reuse the existing conversation between the two users
+	var room models.Room
+	err = database.DB.Joins("JOIN room_users AS a ON a.room_id = rooms.id AND a.user_id = ?", userID).
+		Joins("JOIN room_users AS b ON b.room_id = rooms.id AND b.user_id = ?", targetID).
+		Where("rooms.is_direct").
+		Preload("Users").
+		First(&room).Error
+	if err == nil {
+		c.JSON(http.StatusOK, gin.H{"room": room})
+		return
+	}
+
+	// Blocks and friends-only privacy get the same answer so a block is not revealed
+	if hasBlocked(targetID, userID) || !acceptsInvitesFrom(targetID, userID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can't send messages to this user"})
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		room = models.Room{
+			Name:      "Direct message",
+			CreatedBy: userID,
+			IsDirect:  true,
+		}
+		if err := tx.Create(&room).Error; err != nil {
+			return err
+		}
+		return tx.Create(&[]models.RoomUser{
+			{RoomID: room.ID, UserID: userID},
+			{RoomID: room.ID, UserID: targetID},
+		}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create direct message"})
+		return
+	}
+
+	database.DB.Preload("Users").First(&room, room.ID)
+	subscribeRoomMembers(room.ID)
+	notifyRoomMembers(room.ID, "room_created", room)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Direct message created successfully",
+		"room":    room,
+	})
+}
+
 // GetRoom returns details of a specific room
 func GetRoom(c *gin.Context) {
 	userID := c.MustGet("userID").(uint)
@@ -132,6 +273,12 @@ func UpdateRoom(c *gin.Context) {
 		return
 	}
 
+	var room models.Room
+	if err := database.DB.First(&room, roomID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Room not found"})
+		return
+	}
+
 	// Update room name if provided
 	if input.Name != "" {
 		if err := database.DB.Model(&models.Room{}).Where("id = ?", roomID).Update("name", input.Name).Error; err != nil {
@@ -141,30 +288,343 @@ func UpdateRoom(c *gin.Context) {
 	}
 
 	// Update room members if provided
-	if input.UserIDs != nil {
-		// Remove all existing members except the creator
-		if err := database.DB.Where("room_id = ? AND user_id != ?", roomID, userID).Delete(&models.RoomUser{}).Error; err != nil {
+	var addedIDs, removedIDs []uint
+	if input.UserIDs != nil || input.Handles != nil {
+		memberIDs, err := resolveMemberIDs(input.UserIDs, input.Handles)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if room.IsDirect {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Members of a direct message can't be changed"})
+			return
+		}
+
+		// Only users who are not already members need to accept invitations
+		currentIDs, err := roomMemberIDs(uint(roomID))
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update room members"})
 			return
 		}
+		current := make(map[uint]bool, len(currentIDs))
+		for _, id := range currentIDs {
+			current[id] = true
+		}
+		var invitedIDs []uint
+		for _, id := range memberIDs {
+			if !current[id] {
+				invitedIDs = append(invitedIDs, id)
+			}
+		}
+		invitedIDs, err = filterInvitations(userID, invitedIDs)
+		if err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+
+		// The requesting user always stays a member
+		wanted := map[uint]bool{userID: true}
+		for _, id := range memberIDs {
+			if current[id] {
+				wanted[id] = true
+			}
+		}
+		for _, id := range invitedIDs {
+			wanted[id] = true
+		}
+
+		// Remove members who are no longer wanted
+		for _, id := range currentIDs {
+			if wanted[id] {
+				continue
+			}
+			if err := database.DB.Where("room_id = ? AND user_id = ?", roomID, id).Delete(&models.RoomUser{}).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update room members"})
+				return
+			}
+			removedIDs = append(removedIDs, id)
+		}
 
 		// Add new members
-		for _, id := range input.UserIDs {
-			if id == userID {
-				continue // Skip creator as they're already a member
+		for id := range wanted {
+			if current[id] {
+				continue
 			}
 
 			roomUser := models.RoomUser{
 				RoomID: uint(roomID),
 				UserID: id,
 			}
-			database.DB.Create(&roomUser)
+			if err := database.DB.Create(&roomUser).Error; err == nil {
+				addedIDs = append(addedIDs, id)
+			}
 		}
 	}
 
+	// Tell everyone affected about the change
+	database.DB.Preload("Users").First(&room, roomID)
+	notifyRoomMembers(room.ID, "room_updated", room)
+	notifyMembersAdded(room, addedIDs)
+	notifyMembersRemoved(room.ID, removedIDs)
+
 	c.JSON(http.StatusOK, gin.H{"message": "Room updated successfully"})
 }
 
+// AddRoomMembers adds users to a room without touching existing members
+func AddRoomMembers(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+	roomID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid room ID"})
+		return
+	}
+
+	// Check if user is a member of the room
+	var roomUser models.RoomUser
+	if err := database.DB.Where("room_id = ? AND user_id = ?", roomID, userID).First(&roomUser).Error; err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have access to this room"})
+		return
+	}
+
+	var input AddRoomMembersInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var room models.Room
+	if err := database.DB.First(&room, roomID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Room not found"})
+		return
+	}
+	if room.IsDirect {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Members of a direct message can't be changed"})
+		return
+	}
+
+	memberIDs, err := resolveMemberIDs(input.UserIDs, input.Handles)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	memberIDs, err = filterInvitations(userID, memberIDs)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	addMembers(room, memberIDs)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Members added successfully"})
+}
+
+// MarkRoomRead records that the authenticated user has read a room up to now
+func MarkRoomRead(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+	roomID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid room ID"})
+		return
+	}
+
+	now := time.Now()
+	result := database.DB.Model(&models.RoomUser{}).
+		Where("room_id = ? AND user_id = ?", roomID, userID).
+		Update("last_read_at", now)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark room as read"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have access to this room"})
+		return
+	}
+
+	// Mentions in the room have been seen too
+	database.DB.Model(&models.Mention{}).
+		Where("room_id = ? AND user_id = ? AND read_at IS NULL", roomID, userID).
+		Update("read_at", now)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Room marked as read"})
+}
+
+// UpdateRoomNotifications changes the authenticated user's notification level or mute for a room
+func UpdateRoomNotifications(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+	roomID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid room ID"})
+		return
+	}
+
+	// Check if user is a member of the room
+	var roomUser models.RoomUser
+	if err := database.DB.Where("room_id = ? AND user_id = ?", roomID, userID).First(&roomUser).Error; err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have access to this room"})
+		return
+	}
+
+	var input UpdateRoomNotificationsInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updates := map[string]interface{}{}
+
+	// "default" goes back to following the user's preferences
+	if input.Level != nil {
+		level := *input.Level
+		if level == "default" {
+			level = ""
+		}
+		updates["notification_level"] = level
+	}
+
+	switch {
+	case input.Unmute:
+		updates["muted_until"] = nil
+	case input.MuteFor != "":
+		duration, err := time.ParseDuration(input.MuteFor)
+		if err != nil || duration <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "mute_for must be a positive duration such as 8h"})
+			return
+		}
+		updates["muted_until"] = time.Now().Add(duration)
+	case input.MuteUntil != nil:
+		updates["muted_until"] = *input.MuteUntil
+	}
+
+	if len(updates) > 0 {
+		if err := database.DB.Model(&models.RoomUser{}).
+			Where("room_id = ? AND user_id = ?", roomID, userID).
+			Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification settings"})
+			return
+		}
+	}
+
+	database.DB.Where("room_id = ? AND user_id = ?", roomID, userID).First(&roomUser)
+	settings := models.RoomNotificationSettings{Level: roomUser.NotificationLevel}
+	if roomUser.Muted(time.Now()) {
+		settings.MutedUntil = roomUser.MutedUntil
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Notification settings updated successfully",
+		"notifications": settings,
+	})
+}
+
+// UpdateRoomRetention sets how long messages in a room are kept, such as 24h or 7d, or "off" to keep them
+func UpdateRoomRetention(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+	roomID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid room ID"})
+		return
+	}
+
+	if !isRoomAdmin(uint(roomID), userID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only room admins can change message retention"})
+		return
+	}
+
+	var input UpdateRoomRetentionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var ttl time.Duration
+	if input.MessageTTL != "off" {
+		ttl, err = retention.ParseTTL(input.MessageTTL)
+		if err != nil || ttl < retention.MinTTL {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "message_ttl must be a duration from 1m to 3650d such as 24h or 7d, or off"})
+			return
+		}
+	}
+
+	if err := database.DB.Model(&models.Room{}).Where("id = ?", roomID).Update("message_ttl", int64(ttl.Seconds())).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update message retention"})
+		return
+	}
+
+	audit.Record(models.AuditLog{
+		Action:    audit.ActionRetentionPolicy,
+		UserID:    &userID,
+		IPAddress: c.ClientIP(),
+		Details:   fmt.Sprintf("set message TTL of room %d to %s", roomID, input.MessageTTL),
+	})
+
+	var room models.Room
+	database.DB.Preload("Users").First(&room, roomID)
+	notifyRoomMembers(room.ID, "room_updated", room)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Message retention updated successfully",
+		"room":       room,
+		"global_ttl": int64(retention.GlobalTTL().Seconds()),
+	})
+}
+
+// UpdateMemberRole makes a room member an admin or a regular member
+func UpdateMemberRole(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+	roomID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid room ID"})
+		return
+	}
+	memberID, err := strconv.ParseUint(c.Param("userId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var input UpdateMemberRoleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Only the owner hands out admin rights
+	var room models.Room
+	if err := database.DB.First(&room, roomID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Room not found"})
+		return
+	}
+	if room.CreatedBy != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the room owner can change member roles"})
+		return
+	}
+	if uint(memberID) == room.CreatedBy {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The owner's role can't be changed"})
+		return
+	}
+
+	result := database.DB.Model(&models.RoomUser{}).
+		Where("room_id = ? AND user_id = ?", roomID, memberID).
+		Update("role", input.Role)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update member role"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User is not a member of this room"})
+		return
+	}
+
+	notifyRoomMembers(room.ID, "member_role_updated", gin.H{
+		"room_id": room.ID,
+		"user_id": memberID,
+		"role":    input.Role,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member role updated successfully"})
+}
+
 // DeleteRoom deletes a room
 func DeleteRoom(c *gin.Context) {
 	userID := c.MustGet("userID").(uint)
@@ -186,12 +646,83 @@ func DeleteRoom(c *gin.Context) {
 		return
 	}
 
+	// Remember who to notify once the memberships are gone
+	memberIDs, err := roomMemberIDs(room.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete room members"})
+		return
+	}
+
 	// Delete room users
 	if err := database.DB.Where("room_id = ?", roomID).Delete(&models.RoomUser{}).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete room members"})
 		return
 	}
 
+	// Delete mentions
+	if err := database.DB.Where("room_id = ?", roomID).Delete(&models.Mention{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete room mentions"})
+		return
+	}
+
+	// Drop messages still waiting to be sent
+	if err := database.DB.Where("room_id = ?", roomID).Delete(&models.ScheduledMessage{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete scheduled messages"})
+		return
+	}
+
+	// Delete pins
+	if err := database.DB.Where("room_id = ?", roomID).Delete(&models.Pin{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete room pins"})
+		return
+	}
+
+	// Delete bookmarks
+	if err := database.DB.Where("room_id = ?", roomID).Delete(&models.Bookmark{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete room bookmarks"})
+		return
+	}
+
+	// Delete polls and their votes
+	pollIDs := database.DB.Model(&models.Poll{}).Select("id").Where("room_id = ?", roomID)
+	if err := database.DB.Where("poll_id IN (?)", pollIDs).Delete(&models.PollVote{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete room polls"})
+		return
+	}
+	if err := database.DB.Where("poll_id IN (?)", pollIDs).Delete(&models.PollOption{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete room polls"})
+		return
+	}
+	if err := database.DB.Where("room_id = ?", roomID).Delete(&models.Poll{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete room polls"})
+		return
+	}
+
+	// Delete webhooks and their delivery logs
+	hookIDs := database.DB.Model(&models.Webhook{}).Select("id").Where("room_id = ?", roomID)
+	if err := database.DB.Where("webhook_id IN (?)", hookIDs).Delete(&models.WebhookDelivery{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete room webhooks"})
+		return
+	}
+	if err := database.DB.Where("room_id = ?", roomID).Delete(&models.Webhook{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete room webhooks"})
+		return
+	}
+
+	// Revoke incoming webhooks and bot commands and retire their bot users
+	var botIDs, commandBotIDs []uint
+	database.DB.Model(&models.IncomingWebhook{}).Where("room_id = ?", roomID).Pluck("user_id", &botIDs)
+	database.DB.Model(&models.BotCommand{}).Where("room_id = ?", roomID).Pluck("user_id", &commandBotIDs)
+	if err := database.DB.Where("room_id = ?", roomID).Delete(&models.IncomingWebhook{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete room webhooks"})
+		return
+	}
+	if err := database.DB.Where("room_id = ?", roomID).Delete(&models.BotCommand{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete room commands"})
+		return
+	}
+	retireBotUsers(database.DB, append(botIDs, commandBotIDs...))
+
 	// Delete messages
 	if err := database.DB.Where("room_id = ?", roomID).Delete(&models.Message{}).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete room messages"})
@@ -204,5 +735,169 @@ func DeleteRoom(c *gin.Context) {
 		return
 	}
 
+	for _, id := range memberIDs {
+		websocket.RemoveUserFromRoom(id, room.ID)
+		websocket.SendToUser(id, "room_deleted", gin.H{"room_id": room.ID})
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "Room deleted successfully"})
 }
+
+// resolveMemberIDs merges raw user IDs with the IDs of users given by username#tag handle
+func resolveMemberIDs(userIDs []uint, handles []string) ([]uint, error) {
+	ids := append([]uint{}, userIDs...)
+	for _, handle := range handles {
+		id, err := resolveUser(0, handle)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// resolveUser returns the ID of the user given either directly or by username#tag handle
+func resolveUser(userID uint, handle string) (uint, error) {
+	if handle == "" {
+		if userID == 0 {
+			return 0, errors.New("user_id or handle is required")
+		}
+		var user models.User
+		if err := database.DB.Where("id = ? AND deleted_at IS NULL AND NOT is_bot", userID).First(&user).Error; err != nil {
+			return 0, errors.New("user not found")
+		}
+		return user.ID, nil
+	}
+
+	username, tag, err := models.ParseHandle(handle)
+	if err != nil {
+		return 0, err
+	}
+
+	var user models.User
+	if err := database.DB.Where("username = ? AND tag = ? AND deleted_at IS NULL AND NOT is_bot", username, tag).First(&user).Error; err != nil {
+		return 0, fmt.Errorf("user %s not found", handle)
+	}
+	return user.ID, nil
+}
+
+// filterInvitations returns the users the actor may add to a room. Users who
+// blocked the actor are silently left out so the block is not revealed.
+func filterInvitations(actorID uint, userIDs []uint) ([]uint, error) {
+	allowed := make([]uint, 0, len(userIDs))
+	for _, id := range userIDs {
+		if id == actorID {
+			allowed = append(allowed, id)
+			continue
+		}
+		if hasBlocked(id, actorID) {
+			continue
+		}
+		if !acceptsInvitesFrom(id, actorID) {
+			return nil, fmt.Errorf("user %d only accepts invitations from friends", id)
+		}
+		allowed = append(allowed, id)
+	}
+	return allowed, nil
+}
+
+// roomMemberIDs returns the IDs of all members of a room
+func roomMemberIDs(roomID uint) ([]uint, error) {
+	var ids []uint
+	err := database.DB.Model(&models.RoomUser{}).Where("room_id = ?", roomID).Pluck("user_id", &ids).Error
+	return ids, err
+}
+
+// addMembers adds users to a room, skipping existing members, and tells
+// everyone affected. It returns the IDs of the users who were added.
+func addMembers(room models.Room, userIDs []uint) []uint {
+	var addedIDs []uint
+	for _, id := range userIDs {
+		roomUser := models.RoomUser{
+			RoomID: room.ID,
+			UserID: id,
+		}
+		result := database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&roomUser)
+		if result.Error == nil && result.RowsAffected > 0 {
+			addedIDs = append(addedIDs, id)
+		}
+	}
+
+	if len(addedIDs) > 0 {
+		database.DB.Preload("Users").First(&room, room.ID)
+		notifyRoomMembers(room.ID, "room_updated", room)
+		notifyMembersAdded(room, addedIDs)
+	}
+	return addedIDs
+}
+
+// notifyRoomMembers sends an event to every connection of every member of a room
+func notifyRoomMembers(roomID uint, msgType string, payload interface{}) {
+	memberIDs, err := roomMemberIDs(roomID)
+	if err != nil {
+		log.Printf("error loading members of room %d: %v", roomID, err)
+		return
+	}
+
+	for _, id := range memberIDs {
+		websocket.SendToUser(id, msgType, payload)
+	}
+}
+
+// subscribeRoomMembers joins the auto-joining connections of every member to a room
+func subscribeRoomMembers(roomID uint) {
+	memberIDs, err := roomMemberIDs(roomID)
+	if err != nil {
+		log.Printf("error loading members of room %d: %v", roomID, err)
+		return
+	}
+
+	for _, id := range memberIDs {
+		websocket.AddUserToRoom(id, roomID)
+	}
+}
+
+// notifyMembersAdded subscribes newly added users to a room and tells them they joined
+func notifyMembersAdded(room models.Room, userIDs []uint) {
+	for _, id := range userIDs {
+		websocket.AddUserToRoom(id, room.ID)
+		websocket.SendToUser(id, "added_to_room", room)
+	}
+	dispatchMemberEvents(room.ID, models.EventMemberJoined, userIDs)
+}
+
+// notifyMembersRemoved unsubscribes removed users from a room and tells them they left
+func notifyMembersRemoved(roomID uint, userIDs []uint) {
+	for _, id := range userIDs {
+		websocket.RemoveUserFromRoom(id, roomID)
+		websocket.SendToUser(id, "removed_from_room", gin.H{"room_id": roomID})
+	}
+	dispatchMemberEvents(roomID, models.EventMemberLeft, userIDs)
+}
+
+// dispatchMemberEvents queues a member.joined or member.left webhook event for each user
+func dispatchMemberEvents(roomID uint, event string, userIDs []uint) {
+	if len(userIDs) == 0 {
+		return
+	}
+
+	var users []models.User
+	if err := database.DB.Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+		log.Printf("error loading members of room %d: %v", roomID, err)
+		return
+	}
+	for _, user := range users {
+		webhooks.Dispatch(roomID, event, gin.H{"user": webhooks.UserData(user)})
+	}
+}
+
+// isRoomAdmin checks if a user is the creator, owner, or an admin of a room
+func isRoomAdmin(roomID, userID uint) bool {
+	var count int64
+	database.DB.Model(&models.RoomUser{}).
+		Joins("JOIN rooms ON rooms.id = room_users.room_id").
+		Where("room_users.room_id = ? AND room_users.user_id = ?", roomID, userID).
+		Where("(room_users.role IN ? OR rooms.created_by = room_users.user_id)", []string{models.RoleOwner, models.RoleAdmin}).
+		Count(&count)
+	return count > 0
+}
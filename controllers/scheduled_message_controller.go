@@ -0,0 +1,176 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/CUknot/network_backend/commands"
+	"github.com/CUknot/network_backend/database"
+	"github.com/CUknot/network_backend/models"
+	"github.com/gin-gonic/gin"
+)
+
+// Furthest ahead a message can be scheduled
+const maxScheduleAhead = 365 * 24 * time.Hour
+
+type UpdateScheduledMessageInput struct {
+	Content *string    `json:"content" binding:"omitempty,min=1"`
+	SendAt  *time.Time `json:"send_at"`
+}
+
+// scheduleMessage queues a message from CreateMessage to be sent at input.SendAt
+func scheduleMessage(c *gin.Context, userID uint, input CreateMessageInput) {
+	if _, _, ok := commands.Parse(input.Content); ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Slash commands can't be scheduled"})
+		return
+	}
+	if input.SendAt.After(time.Now().Add(maxScheduleAhead)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Messages can be scheduled at most a year ahead"})
+		return
+	}
+
+	scheduled := models.ScheduledMessage{
+		RoomID:      input.RoomID,
+		UserID:      userID,
+		ClientMsgID: clientMsgID(input.ClientMsgID),
+		Content:     commands.Unescape(input.Content),
+		SendAt:      *input.SendAt,
+		Status:      models.ScheduledPending,
+	}
+
+	// A retry gets the originally scheduled message back
+	if scheduled.ClientMsgID != nil {
+		var original models.ScheduledMessage
+		if err := database.DB.Where("user_id = ? AND room_id = ? AND client_msg_id = ?", userID, input.RoomID, input.ClientMsgID).
+			First(&original).Error; err == nil {
+			c.JSON(http.StatusOK, gin.H{
+				"message":   "Message already scheduled",
+				"scheduled": original,
+			})
+			return
+		}
+	}
+
+	if err := database.DB.Create(&scheduled).Error; err != nil {
+		if database.IsUniqueViolation(err, "idx_scheduled_client_msg") {
+			c.JSON(http.StatusConflict, gin.H{"error": "A message with this client_msg_id is already scheduled"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to schedule message"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":   "Message scheduled successfully",
+		"scheduled": scheduled,
+	})
+}
+
+// GetScheduledMessages returns the authenticated user's pending scheduled messages,
+// optionally limited to one room with room_id
+func GetScheduledMessages(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	query := database.DB.Where("user_id = ? AND status = ?", userID, models.ScheduledPending)
+	if c.Query("room_id") != "" {
+		roomID, err := strconv.ParseUint(c.Query("room_id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid room ID"})
+			return
+		}
+		query = query.Where("room_id = ?", roomID)
+	}
+
+	var scheduled []models.ScheduledMessage
+	if err := query.Order("send_at ASC").Find(&scheduled).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scheduled messages"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"scheduled": scheduled})
+}
+
+// UpdateScheduledMessage changes the content or send time of a pending scheduled message
+func UpdateScheduledMessage(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	scheduledID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scheduled message ID"})
+		return
+	}
+
+	var input UpdateScheduledMessageInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if input.Content != nil {
+		if _, _, ok := commands.Parse(*input.Content); ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Slash commands can't be scheduled"})
+			return
+		}
+		updates["content"] = commands.Unescape(*input.Content)
+	}
+	if input.SendAt != nil {
+		now := time.Now()
+		if !input.SendAt.After(now) || input.SendAt.After(now.Add(maxScheduleAhead)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "send_at must be in the future and at most a year ahead"})
+			return
+		}
+		updates["send_at"] = *input.SendAt
+	}
+	if len(updates) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nothing to update"})
+		return
+	}
+
+	// Only pending messages change; the scheduler holds a lock on messages it is sending
+	result := database.DB.Model(&models.ScheduledMessage{}).
+		Where("id = ? AND user_id = ? AND status = ?", uint(scheduledID), userID, models.ScheduledPending).
+		Updates(updates)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update scheduled message"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Scheduled message not found or already sent"})
+		return
+	}
+
+	var scheduled models.ScheduledMessage
+	database.DB.First(&scheduled, uint(scheduledID))
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Scheduled message updated successfully",
+		"scheduled": scheduled,
+	})
+}
+
+// CancelScheduledMessage cancels a pending scheduled message
+func CancelScheduledMessage(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	scheduledID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scheduled message ID"})
+		return
+	}
+
+	result := database.DB.Model(&models.ScheduledMessage{}).
+		Where("id = ? AND user_id = ? AND status = ?", uint(scheduledID), userID, models.ScheduledPending).
+		Update("status", models.ScheduledCancelled)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel scheduled message"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Scheduled message not found or already sent"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Scheduled message cancelled successfully"})
+}
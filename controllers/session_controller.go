@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/CUknot/network_backend/database"
+	"github.com/CUknot/network_backend/models"
+	"github.com/CUknot/network_backend/utils"
+	"github.com/CUknot/network_backend/websocket"
+	"github.com/gin-gonic/gin"
+)
+
+// GetSessions returns all active sessions for the authenticated user
+func GetSessions(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+	sessionID := c.MustGet("sessionID").(uint)
+
+	var sessions []models.Session
+	if err := database.DB.Where("user_id = ?", userID).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sessions"})
+		return
+	}
+
+	// Flag the session making this request
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == sessionID
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+// RevokeSession logs out a single session of the authenticated user
+func RevokeSession(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+	sessionID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	var session models.Session
+	if err := database.DB.Where("id = ? AND user_id = ?", sessionID, userID).First(&session).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+
+	if err := revokeSessions([]uint{session.ID}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
+
+// RevokeOtherSessions logs the authenticated user out everywhere except the current session
+func RevokeOtherSessions(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+	sessionID := c.MustGet("sessionID").(uint)
+
+	if err := revokeOtherSessions(userID, sessionID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out of all other sessions"})
+}
+
+// startSession records a new session for the user and issues a token bound to it
+func startSession(c *gin.Context, userID uint, deviceName string) (string, error) {
+	session := models.Session{
+		UserID:     userID,
+		DeviceName: deviceName,
+		UserAgent:  c.Request.UserAgent(),
+		IPAddress:  c.ClientIP(),
+		LastSeenAt: time.Now(),
+	}
+	if err := database.DB.Create(&session).Error; err != nil {
+		return "", err
+	}
+
+	return utils.GenerateToken(userID, session.ID)
+}
+
+// revokeOtherSessions revokes every session of the user except the one given
+func revokeOtherSessions(userID, keepSessionID uint) error {
+	var sessionIDs []uint
+	if err := database.DB.Model(&models.Session{}).
+		Where("user_id = ? AND id != ?", userID, keepSessionID).
+		Pluck("id", &sessionIDs).Error; err != nil {
+		return err
+	}
+
+	return revokeSessions(sessionIDs)
+}
+
+// revokeSessions deletes sessions and closes any websocket connections opened with them
+func revokeSessions(sessionIDs []uint) error {
+	if len(sessionIDs) == 0 {
+		return nil
+	}
+
+	if err := database.DB.Where("id IN ?", sessionIDs).Delete(&models.Session{}).Error; err != nil {
+		return err
+	}
+
+	for _, id := range sessionIDs {
+		websocket.DisconnectSession(id)
+	}
+	return nil
+}
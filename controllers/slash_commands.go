@@ -0,0 +1,196 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/CUknot/network_backend/commands"
+	"github.com/CUknot/network_backend/database"
+	"github.com/CUknot/network_backend/models"
+)
+
+// Longest room topic allowed
+const maxTopicLength = 512
+
+// How long /mute mutes a room when no duration is given
+const defaultMuteDuration = 8 * time.Hour
+
+// RegisterCommands registers the built-in slash commands
+func RegisterCommands() {
+	commands.Register(commands.Command{
+		Name:        "me",
+		Usage:       "/me <action>",
+		Description: "Describe what you are doing",
+		Handler:     meCommand,
+	})
+	commands.Register(commands.Command{
+		Name:        "topic",
+		Usage:       "/topic [new topic]",
+		Description: "Show or set the room topic",
+		Handler:     topicCommand,
+	})
+	commands.Register(commands.Command{
+		Name:        "invite",
+		Usage:       "/invite @username#tag...",
+		Description: "Add people to the room",
+		Handler:     inviteCommand,
+	})
+	commands.Register(commands.Command{
+		Name:        "leave",
+		Usage:       "/leave",
+		Description: "Leave the room",
+		Handler:     leaveCommand,
+	})
+	commands.Register(commands.Command{
+		Name:        "mute",
+		Usage:       "/mute [duration|off]",
+		Description: "Mute the room's notifications, for 8h by default",
+		Handler:     muteCommand,
+	})
+	commands.Register(commands.Command{
+		Name:        "help",
+		Usage:       "/help",
+		Description: "List the commands available in the room",
+		Handler:     helpCommand,
+	})
+}
+
+// meCommand posts an action message
+func meCommand(ctx commands.Context) (commands.Response, error) {
+	if ctx.Args == "" {
+		return commands.Response{Ephemeral: "Usage: /me <action>"}, nil
+	}
+	return commands.Response{Message: &models.Message{Type: models.MessageAction, Content: ctx.Args}}, nil
+}
+
+// topicCommand shows the room topic, or sets it and announces the change
+func topicCommand(ctx commands.Context) (commands.Response, error) {
+	var room models.Room
+	if err := database.DB.First(&room, ctx.RoomID).Error; err != nil {
+		return commands.Response{}, err
+	}
+
+	if ctx.Args == "" {
+		if room.Topic == "" {
+			return commands.Response{Ephemeral: "This room has no topic"}, nil
+		}
+		return commands.Response{Ephemeral: "Topic: " + room.Topic}, nil
+	}
+	if utf8.RuneCountInString(ctx.Args) > maxTopicLength {
+		return commands.Response{Ephemeral: fmt.Sprintf("Topics can be at most %d characters", maxTopicLength)}, nil
+	}
+
+	if err := database.DB.Model(&models.Room{}).Where("id = ?", room.ID).Update("topic", ctx.Args).Error; err != nil {
+		return commands.Response{}, err
+	}
+	database.DB.Preload("Users").First(&room, room.ID)
+	notifyRoomMembers(room.ID, "room_updated", room)
+
+	return commands.Response{Message: &models.Message{
+		Type:    models.MessageAction,
+		Content: "set the topic: " + ctx.Args,
+	}}, nil
+}
+
+// inviteCommand adds the users given by handle to the room
+func inviteCommand(ctx commands.Context) (commands.Response, error) {
+	var handles []string
+	for _, handle := range strings.Fields(ctx.Args) {
+		handles = append(handles, strings.TrimPrefix(handle, "@"))
+	}
+	if len(handles) == 0 {
+		return commands.Response{Ephemeral: "Usage: /invite @username#tag..."}, nil
+	}
+
+	var room models.Room
+	if err := database.DB.First(&room, ctx.RoomID).Error; err != nil {
+		return commands.Response{}, err
+	}
+	if room.IsDirect {
+		return commands.Response{Ephemeral: "Members of a direct message can't be changed"}, nil
+	}
+
+	memberIDs, err := resolveMemberIDs(nil, handles)
+	if err != nil {
+		return commands.Response{Ephemeral: err.Error()}, nil
+	}
+	memberIDs, err = filterInvitations(ctx.UserID, memberIDs)
+	if err != nil {
+		return commands.Response{Ephemeral: err.Error()}, nil
+	}
+
+	// Blocked invitations count as sent so the block is not revealed
+	addMembers(room, memberIDs)
+	return commands.Response{Ephemeral: fmt.Sprintf("Invited %s", strings.Join(handles, ", "))}, nil
+}
+
+// leaveCommand removes the invoker from the room
+func leaveCommand(ctx commands.Context) (commands.Response, error) {
+	var room models.Room
+	if err := database.DB.First(&room, ctx.RoomID).Error; err != nil {
+		return commands.Response{}, err
+	}
+	if room.IsDirect {
+		return commands.Response{Ephemeral: "You can't leave a direct message"}, nil
+	}
+	if room.CreatedBy == ctx.UserID {
+		return commands.Response{Ephemeral: "The room owner can't leave, delete the room instead"}, nil
+	}
+
+	if err := database.DB.Where("room_id = ? AND user_id = ?", ctx.RoomID, ctx.UserID).Delete(&models.RoomUser{}).Error; err != nil {
+		return commands.Response{}, err
+	}
+
+	database.DB.Preload("Users").First(&room, room.ID)
+	notifyRoomMembers(room.ID, "room_updated", room)
+	notifyMembersRemoved(room.ID, []uint{ctx.UserID})
+
+	return commands.Response{Ephemeral: "You left " + room.Name}, nil
+}
+
+// muteCommand mutes the room for the invoker, or unmutes it with "off"
+func muteCommand(ctx commands.Context) (commands.Response, error) {
+	query := database.DB.Model(&models.RoomUser{}).Where("room_id = ? AND user_id = ?", ctx.RoomID, ctx.UserID)
+
+	if ctx.Args == "off" {
+		if err := query.Update("muted_until", nil).Error; err != nil {
+			return commands.Response{}, err
+		}
+		return commands.Response{Ephemeral: "Notifications for this room are back on"}, nil
+	}
+
+	duration := defaultMuteDuration
+	if ctx.Args != "" {
+		var err error
+		duration, err = time.ParseDuration(ctx.Args)
+		if err != nil || duration <= 0 {
+			return commands.Response{Ephemeral: "Usage: /mute [duration|off], for example /mute 2h"}, nil
+		}
+	}
+
+	until := time.Now().Add(duration)
+	if err := query.Update("muted_until", until).Error; err != nil {
+		return commands.Response{}, err
+	}
+	return commands.Response{Ephemeral: "Room muted until " + until.Format(time.RFC1123)}, nil
+}
+
+// helpCommand lists the built-in commands and the room's bot commands
+func helpCommand(ctx commands.Context) (commands.Response, error) {
+	var lines []string
+	for _, command := range commands.Builtins() {
+		lines = append(lines, fmt.Sprintf("%s - %s", command.Usage, command.Description))
+	}
+
+	var bots []models.BotCommand
+	if err := database.DB.Where("room_id = ?", ctx.RoomID).Order("name ASC").Find(&bots).Error; err != nil {
+		return commands.Response{}, err
+	}
+	for _, bot := range bots {
+		lines = append(lines, fmt.Sprintf("/%s - %s", bot.Name, bot.Description))
+	}
+
+	return commands.Response{Ephemeral: strings.Join(lines, "\n")}, nil
+}
@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/CUknot/network_backend/database"
+	"github.com/CUknot/network_backend/models"
+)
+
+const (
+	// Name of the unique index over username and tag
+	usernameTagIndex = "idx_username_tag"
+
+	// Number of alternative tags offered when a requested pair is taken
+	tagSuggestionCount = 5
+
+	// Attempts made to claim a random tag before giving up
+	tagAllocationAttempts = 5
+
+	// Minimum time between two tag changes of the same user
+	tagChangeCooldown = 7 * 24 * time.Hour
+)
+
+var errNoFreeTags = errors.New("no free tags left for this username")
+
+// freeTags returns up to n random numeric tags not yet used with username
+func freeTags(username string, n int) ([]string, error) {
+	var taken []string
+	if err := database.DB.Model(&models.User{}).Where("username = ?", username).Pluck("tag", &taken).Error; err != nil {
+		return nil, err
+	}
+
+	used := make(map[string]bool, len(taken))
+	for _, tag := range taken {
+		used[tag] = true
+	}
+
+	var free []string
+	for i := 0; i < 10000; i++ {
+		if tag := fmt.Sprintf("%04d", i); !used[tag] {
+			free = append(free, tag)
+		}
+	}
+
+	rand.Shuffle(len(free), func(i, j int) {
+		free[i], free[j] = free[j], free[i]
+	})
+	if len(free) > n {
+		free = free[:n]
+	}
+	return free, nil
+}
+
+// createUserWithFreeTag inserts a user under a random unused tag, retrying if a
+// concurrent registration claims the same tag first
+func createUserWithFreeTag(user *models.User) error {
+	password := user.Password
+
+	var err error
+	for attempt := 0; attempt < tagAllocationAttempts; attempt++ {
+		var tags []string
+		tags, err = freeTags(user.Username, 1)
+		if err != nil {
+			return err
+		}
+		if len(tags) == 0 {
+			return errNoFreeTags
+		}
+
+		// BeforeSave hashes the password in place, so restore it for each attempt
+		user.Tag = tags[0]
+		user.Password = password
+		err = database.DB.Create(user).Error
+		if !database.IsUniqueViolation(err, usernameTagIndex) {
+			return err
+		}
+	}
+	return err
+}
@@ -0,0 +1,445 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CUknot/network_backend/database"
+	"github.com/CUknot/network_backend/models"
+	"github.com/CUknot/network_backend/websocket"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Maximum number of results returned by a user search
+const userSearchLimit = 20
+
+type UpdateProfileInput struct {
+	Username        *string `json:"username" binding:"omitempty,min=1,max=255"`
+	Tag             *string `json:"tag" binding:"omitempty,len=4"`
+	Email           *string `json:"email" binding:"omitempty,email"`
+	CurrentPassword string  `json:"current_password"`
+}
+
+type ChangePasswordInput struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=6"`
+}
+
+type UpdatePreferencesInput struct {
+	Theme             *string `json:"theme" binding:"omitempty,oneof=light dark system"`
+	NotificationLevel *string `json:"notification_level" binding:"omitempty,oneof=all mentions none"`
+	NotificationSound *bool   `json:"notification_sound"`
+	FriendsOnly       *bool   `json:"friends_only"`
+	DigestFrequency   *string `json:"digest_frequency" binding:"omitempty,oneof=off daily weekly"`
+}
+
+type DeleteAccountInput struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// GetMe returns the authenticated user's profile and preferences
+func GetMe(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	preferences, err := loadPreferences(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch preferences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user":          user,
+		"preferences":   preferences,
+		"pending_email": pendingEmail(userID),
+	})
+}
+
+// UpdateMe updates the authenticated user's username, tag, or email
+func UpdateMe(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	var input UpdateProfileInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	updates := map[string]interface{}{}
+
+	// Username and tag together must stay unique
+	username, tag := user.Username, user.Tag
+	if input.Username != nil {
+		username = *input.Username
+	}
+	if input.Tag != nil {
+		tag = *input.Tag
+	}
+	if username != user.Username || tag != user.Tag {
+		// Tags can only be changed once per cooldown period
+		if tag != user.Tag && user.TagChangedAt != nil {
+			if wait := time.Until(user.TagChangedAt.Add(tagChangeCooldown)); wait > 0 {
+				retryAfter := int(math.Ceil(wait.Seconds()))
+				c.Header("Retry-After", strconv.Itoa(retryAfter))
+				c.JSON(http.StatusTooManyRequests, gin.H{
+					"error":       "Tag was changed recently, please try again later",
+					"retry_after": retryAfter,
+				})
+				return
+			}
+		}
+
+		var count int64
+		database.DB.Model(&models.User{}).
+			Where("username = ? AND tag = ? AND id != ?", username, tag, userID).
+			Count(&count)
+		if count > 0 {
+			suggestions, _ := freeTags(username, tagSuggestionCount)
+			c.JSON(http.StatusConflict, gin.H{
+				"error":       "Username and tag combination is already taken",
+				"suggestions": suggestions,
+			})
+			return
+		}
+		updates["username"] = username
+		updates["tag"] = tag
+		if tag != user.Tag {
+			updates["tag_changed_at"] = time.Now()
+		}
+	}
+
+	// Changing the email requires the current password, and takes effect once the new address is confirmed
+	var newEmail string
+	if input.Email != nil && *input.Email != user.Email {
+		if input.CurrentPassword == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Current password is required to change email"})
+			return
+		}
+		if err := user.ValidatePassword(input.CurrentPassword); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Current password is incorrect"})
+			return
+		}
+
+		var count int64
+		database.DB.Model(&models.User{}).Where("email = ? AND id != ?", *input.Email, userID).Count(&count)
+		if count > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "User with this email already exists"})
+			return
+		}
+		newEmail = *input.Email
+	}
+
+	// The profile changes and the pending email change are saved together, and
+	// only once the confirmation email has gone out
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if len(updates) > 0 {
+			if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		if newEmail != "" {
+			return requestEmailChange(tx, user, newEmail)
+		}
+		return nil
+	})
+	if err != nil {
+		if database.IsUniqueViolation(err, "") {
+			c.JSON(http.StatusConflict, gin.H{"error": "Username, tag, or email is already taken"})
+			return
+		}
+		if newEmail != "" {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile or send email confirmation, nothing was changed"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+		return
+	}
+
+	database.DB.First(&user, userID)
+
+	response := gin.H{
+		"message": "Profile updated successfully",
+		"user":    user,
+	}
+	if newEmail != "" {
+		response["message"] = "Profile updated, check your new email address to confirm the change"
+		response["pending_email"] = newEmail
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// ChangePassword changes the authenticated user's password and logs out their other sessions
+func ChangePassword(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+	sessionID := c.MustGet("sessionID").(uint)
+
+	var input ChangePasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if err := user.ValidatePassword(input.CurrentPassword); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Current password is incorrect"})
+		return
+	}
+
+	hashedPassword, err := models.HashPassword(input.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
+		return
+	}
+
+	if err := database.DB.Model(&models.User{}).Where("id = ?", userID).Update("password", hashedPassword).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
+		return
+	}
+
+	// Anyone holding an old session must log in again
+	if err := revokeOtherSessions(userID, sessionID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Password changed but failed to revoke other sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully"})
+}
+
+// GetPreferences returns the authenticated user's preferences
+func GetPreferences(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	preferences, err := loadPreferences(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch preferences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"preferences": preferences})
+}
+
+// UpdatePreferences updates the authenticated user's preferences
+func UpdatePreferences(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	var input UpdatePreferencesInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	preferences, err := loadPreferences(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch preferences"})
+		return
+	}
+
+	if input.Theme != nil {
+		preferences.Theme = *input.Theme
+	}
+	if input.NotificationLevel != nil {
+		preferences.NotificationLevel = *input.NotificationLevel
+	}
+	if input.NotificationSound != nil {
+		preferences.NotificationSound = *input.NotificationSound
+	}
+	if input.FriendsOnly != nil {
+		preferences.FriendsOnly = *input.FriendsOnly
+	}
+	if input.DigestFrequency != nil {
+		preferences.DigestFrequency = *input.DigestFrequency
+	}
+
+	if err := database.DB.Clauses(clause.OnConflict{UpdateAll: true}).Create(&preferences).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update preferences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Preferences updated successfully",
+		"preferences": preferences,
+	})
+}
+
+// DeleteMe deletes the authenticated user's account, keeping their messages under an anonymous author
+func DeleteMe(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	var input DeleteAccountInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if err := user.ValidatePassword(input.Password); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Password is incorrect"})
+		return
+	}
+
+	var sessionIDs []uint
+	database.DB.Model(&models.Session{}).Where("user_id = ?", userID).Pluck("id", &sessionIDs)
+
+	var roomIDs []uint
+	database.DB.Model(&models.RoomUser{}).Where("user_id = ?", userID).Pluck("room_id", &roomIDs)
+
+	// Replace the password with one nobody knows
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete account"})
+		return
+	}
+	hashedPassword, err := models.HashPassword(hex.EncodeToString(secret))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete account"})
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// Scrub the user row but keep it so authored messages still have an author
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"username":   fmt.Sprintf("deleted-user-%d", userID),
+			"tag":        "0000",
+			"email":      fmt.Sprintf("deleted-%d@deleted.invalid", userID),
+			"password":   hashedPassword,
+			"deleted_at": time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RoomUser{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&models.UserPreferences{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&models.PushSubscription{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ? AND status = ?", userID, models.ScheduledPending).Delete(&models.ScheduledMessage{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&models.Bookmark{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&models.EmailChange{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&models.Session{}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete account"})
+		return
+	}
+
+	for _, id := range sessionIDs {
+		websocket.DisconnectSession(id)
+	}
+	for _, id := range roomIDs {
+		dispatchMemberEvents(id, models.EventMemberLeft, []uint{userID})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account deleted successfully"})
+}
+
+// LookupUser finds a user by their exact username#tag handle
+func LookupUser(c *gin.Context) {
+	username, tag, err := models.ParseHandle(c.Query("handle"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.Where("username = ? AND tag = ? AND deleted_at IS NULL AND NOT is_bot", username, tag).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": publicUser(user)})
+}
+
+// SearchUsers finds users by username prefix among the authenticated user's friends and people they share a room with
+func SearchUsers(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+		return
+	}
+
+	// Treat LIKE wildcards in the query literally
+	pattern := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(query) + "%"
+
+	var users []models.User
+	if err := database.DB.Where("username ILIKE ? AND id != ? AND deleted_at IS NULL AND NOT is_bot", pattern, userID).
+		Where("(id IN (?) OR id IN (?))", knownUserIDs(userID), friendIDs(userID)).
+		Order("username ASC, tag ASC").
+		Limit(userSearchLimit).
+		Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search users"})
+		return
+	}
+
+	results := make([]gin.H, len(users))
+	for i, user := range users {
+		results[i] = publicUser(user)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"users": results})
+}
+
+// knownUserIDs returns a subquery selecting everyone who shares a room with the user
+func knownUserIDs(userID uint) *gorm.DB {
+	return database.DB.Table("room_users AS mine").
+		Select("theirs.user_id").
+		Joins("JOIN room_users AS theirs ON theirs.room_id = mine.room_id").
+		Where("mine.user_id = ?", userID)
+}
+
+// publicUser returns the fields of a user that other users may see
+func publicUser(user models.User) gin.H {
+	return gin.H{
+		"id":       user.ID,
+		"username": user.Username,
+		"tag":      user.Tag,
+		"handle":   user.Handle(),
+	}
+}
+
+// loadPreferences returns a user's saved preferences or the defaults
+func loadPreferences(userID uint) (models.UserPreferences, error) {
+	preferences := models.DefaultPreferences(userID)
+	err := database.DB.Where("user_id = ?", userID).Limit(1).Find(&preferences).Error
+	return preferences, err
+}
@@ -0,0 +1,270 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+
+	"github.com/CUknot/network_backend/database"
+	"github.com/CUknot/network_backend/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CreateWebhookInput struct {
+	URL    string   `json:"url" binding:"required,url,startswith=http"`
+	Events []string `json:"events" binding:"required,min=1,dive,oneof=message.created message.edited message.deleted member.joined member.left"`
+}
+
+type UpdateWebhookInput struct {
+	URL          *string  `json:"url" binding:"omitempty,url,startswith=http"`
+	Events       []string `json:"events" binding:"omitempty,min=1,dive,oneof=message.created message.edited message.deleted member.joined member.left"`
+	Active       *bool    `json:"active"`
+	RotateSecret bool     `json:"rotate_secret"`
+}
+
+// GetWebhooks returns the outgoing webhooks of a room
+func GetWebhooks(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+	roomID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid room ID"})
+		return
+	}
+
+	if !isRoomAdmin(uint(roomID), userID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only room admins can manage webhooks"})
+		return
+	}
+
+	var hooks []models.Webhook
+	if err := database.DB.Where("room_id = ?", roomID).Order("id ASC").Find(&hooks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch webhooks"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"webhooks": hooks})
+}
+
+// CreateWebhook registers an outgoing webhook for a room. The signing secret is
+// only returned here and when it is rotated.
+func CreateWebhook(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+	roomID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid room ID"})
+		return
+	}
+
+	if !isRoomAdmin(uint(roomID), userID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only room admins can manage webhooks"})
+		return
+	}
+
+	var input CreateWebhookInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+		return
+	}
+
+	hook := models.Webhook{
+		RoomID:    uint(roomID),
+		URL:       input.URL,
+		Secret:    secret,
+		Events:    input.Events,
+		Active:    true,
+		CreatedBy: userID,
+	}
+	if err := database.DB.Create(&hook).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Webhook created successfully",
+		"webhook": hook,
+		"secret":  secret,
+	})
+}
+
+// UpdateWebhook changes a webhook's URL or events, re-enables it, or rotates its secret
+func UpdateWebhook(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+	roomID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid room ID"})
+		return
+	}
+
+	if !isRoomAdmin(uint(roomID), userID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only room admins can manage webhooks"})
+		return
+	}
+
+	webhookID, err := strconv.ParseUint(c.Param("webhookId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+		return
+	}
+
+	var hook models.Webhook
+	if err := database.DB.Where("id = ? AND room_id = ?", webhookID, roomID).First(&hook).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	var input UpdateWebhookInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if input.URL != nil {
+		updates["url"] = *input.URL
+	}
+	if input.Events != nil {
+		hook.Events = input.Events
+		updates["events"] = hook.Events
+	}
+	if input.Active != nil {
+		updates["active"] = *input.Active
+		// Re-enabling starts the failure count afresh
+		if *input.Active {
+			updates["consecutive_failures"] = 0
+			updates["disabled_at"] = nil
+		}
+	}
+
+	var secret string
+	if input.RotateSecret {
+		secret, err = newWebhookSecret()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update webhook"})
+			return
+		}
+		updates["secret"] = secret
+	}
+
+	if len(updates) > 0 {
+		if err := database.DB.Model(&hook).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update webhook"})
+			return
+		}
+	}
+
+	database.DB.First(&hook, hook.ID)
+	response := gin.H{
+		"message": "Webhook updated successfully",
+		"webhook": hook,
+	}
+	if secret != "" {
+		response["secret"] = secret
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// DeleteWebhook removes a webhook and its delivery log
+func DeleteWebhook(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+	roomID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid room ID"})
+		return
+	}
+
+	if !isRoomAdmin(uint(roomID), userID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only room admins can manage webhooks"})
+		return
+	}
+
+	webhookID, err := strconv.ParseUint(c.Param("webhookId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+		return
+	}
+
+	var hook models.Webhook
+	if err := database.DB.Where("id = ? AND room_id = ?", webhookID, roomID).First(&hook).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("webhook_id = ?", hook.ID).Delete(&models.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&hook).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete webhook"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted successfully"})
+}
+
+// GetWebhookDeliveries returns a webhook's delivery log, newest first. Pass
+// before=<delivery id> to page back through older deliveries.
+func GetWebhookDeliveries(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+	roomID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid room ID"})
+		return
+	}
+
+	if !isRoomAdmin(uint(roomID), userID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only room admins can manage webhooks"})
+		return
+	}
+
+	webhookID, err := strconv.ParseUint(c.Param("webhookId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+		return
+	}
+
+	var hook models.Webhook
+	if err := database.DB.Where("id = ? AND room_id = ?", webhookID, roomID).First(&hook).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	query := database.DB.Where("webhook_id = ?", hook.ID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if before := c.Query("before"); before != "" {
+		beforeID, err := strconv.ParseUint(before, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid before cursor"})
+			return
+		}
+		query = query.Where("id < ?", beforeID)
+	}
+
+	var deliveries []models.WebhookDelivery
+	if err := query.Order("id DESC").Limit(pageSize(c)).Find(&deliveries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch deliveries"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deliveries": deliveries})
+}
+
+// newWebhookSecret generates a random secret for signing deliveries
+func newWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
@@ -1,11 +1,13 @@
 package database
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
 
 	"github.com/CUknot/network_backend/models"
+	"github.com/jackc/pgx/v5/pgconn"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
@@ -54,6 +56,21 @@ func Connect() {
 
 // Migrate automatically migrates the database schema
 func Migrate() {
-	DB.AutoMigrate(&models.User{}, &models.Room{}, &models.Message{}, &models.RoomUser{})
+	DB.AutoMigrate(&models.User{}, &models.Room{}, &models.Message{}, &models.RoomUser{}, &models.Session{},
+		&models.LoginAttempt{}, &models.AuditLog{}, &models.UserPreferences{},
+		&models.FriendRequest{}, &models.Block{}, &models.Mention{},
+		&models.PushSubscription{}, &models.VapidKey{}, &models.Webhook{}, &models.WebhookDelivery{}, &models.IncomingWebhook{},
+		&models.BotCommand{}, &models.Pin{}, &models.ScheduledMessage{}, &models.LinkPreviewCache{},
+		&models.Poll{}, &models.PollOption{}, &models.PollVote{}, &models.Bookmark{}, &models.EmailChange{})
 	log.Println("Database migration completed")
 }
+
+// IsUniqueViolation reports whether err was caused by the named unique constraint,
+// or by any unique constraint when constraint is empty
+func IsUniqueViolation(err error, constraint string) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "23505" {
+		return false
+	}
+	return constraint == "" || pgErr.ConstraintName == constraint
+}
@@ -0,0 +1,267 @@
+package digest
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/CUknot/network_backend/database"
+	"github.com/CUknot/network_backend/mailer"
+	"github.com/CUknot/network_backend/models"
+	"github.com/CUknot/network_backend/notifications"
+	"github.com/CUknot/network_backend/websocket"
+	"gorm.io/gorm/clause"
+)
+
+// Most items listed in a single digest
+const maxItems = 50
+
+// Item is one missed message in a digest
+type Item struct {
+	Room    string
+	Sender  string
+	Content string
+	Time    time.Time
+	Mention bool
+}
+
+// data is passed to the digest templates
+type data struct {
+	Username string
+	Count    int
+	Items    []Item
+	AppURL   string
+}
+
+// Start runs the digest job every DIGEST_INTERVAL (default 15m) for users who have
+// been offline longer than DIGEST_OFFLINE_WINDOW (default 24h)
+func Start() {
+	interval := durationEnv("DIGEST_INTERVAL", 15*time.Minute)
+	window := durationEnv("DIGEST_OFFLINE_WINDOW", 24*time.Hour)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			run(window)
+			<-ticker.C
+		}
+	}()
+}
+
+// run sends digests to every user who is due one
+func run(window time.Duration) {
+	now := time.Now()
+
+	// Users who have been away long enough and whose frequency allows another digest
+	var users []models.User
+	if err := database.DB.Where("deleted_at IS NULL AND NOT is_bot").
+		Where("NOT EXISTS (SELECT 1 FROM sessions WHERE sessions.user_id = users.id AND sessions.last_seen_at > ?)", now.Add(-window)).
+		Where(`NOT EXISTS (SELECT 1 FROM user_preferences p WHERE p.user_id = users.id AND (
+			p.digest_frequency = ? OR
+			(p.digest_frequency = ? AND p.last_digest_at > ?) OR
+			(p.digest_frequency = ? AND p.last_digest_at > ?)))`,
+			models.DigestOff,
+			models.DigestDaily, now.Add(-period(models.DigestDaily)),
+			models.DigestWeekly, now.Add(-period(models.DigestWeekly))).
+		Find(&users).Error; err != nil {
+		log.Printf("error finding digest recipients: %v", err)
+		return
+	}
+
+	for _, user := range users {
+		if websocket.IsOnline(user.ID) {
+			continue
+		}
+		if err := send(user, now); err != nil {
+			log.Printf("error sending digest to user %d: %v", user.ID, err)
+		}
+	}
+}
+
+// send claims and sends a single user's digest
+func send(user models.User, now time.Time) error {
+	// Make sure there is a preferences row to claim
+	defaults := models.DefaultPreferences(user.ID)
+	if err := database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&defaults).Error; err != nil {
+		return err
+	}
+
+	var preferences models.UserPreferences
+	if err := database.DB.Where("user_id = ?", user.ID).First(&preferences).Error; err != nil {
+		return err
+	}
+	if preferences.DigestFrequency == models.DigestOff {
+		return nil
+	}
+
+	// Only one replica may claim this user's digest for the period
+	result := database.DB.Model(&models.UserPreferences{}).
+		Where("user_id = ? AND (last_digest_at IS NULL OR last_digest_at <= ?)", user.ID, now.Add(-period(preferences.DigestFrequency))).
+		Update("last_digest_at", now)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return result.Error
+	}
+
+	// Nothing already covered by the previous digest is repeated
+	var since time.Time
+	if preferences.LastDigestAt != nil {
+		since = *preferences.LastDigestAt
+	}
+
+	items, err := collect(user.ID, since)
+	if err != nil || len(items) == 0 {
+		release(user.ID, preferences.LastDigestAt)
+		return err
+	}
+
+	email, err := render(user, items)
+	if err != nil {
+		release(user.ID, preferences.LastDigestAt)
+		return err
+	}
+
+	if err := mailer.Default.Send(email); err != nil {
+		// Let the next run try again
+		release(user.ID, preferences.LastDigestAt)
+		return err
+	}
+	return nil
+}
+
+// release gives up a claim so the digest is retried on the next run
+func release(userID uint, previous *time.Time) {
+	database.DB.Model(&models.UserPreferences{}).Where("user_id = ?", userID).Update("last_digest_at", previous)
+}
+
+// collect gathers unread direct messages and mentions newer than both the
+// user's read marker for the room and since
+func collect(userID uint, since time.Time) ([]Item, error) {
+	blocked := database.DB.Model(&models.Block{}).Select("blocked_id").Where("blocker_id = ?", userID)
+
+	var direct []models.Message
+	if err := database.DB.Joins("JOIN rooms ON rooms.id = messages.room_id AND rooms.is_direct").
+		Joins("JOIN room_users ON room_users.room_id = messages.room_id AND room_users.user_id = ?", userID).
+		Where("messages.user_id != ? AND messages.user_id NOT IN (?)", userID, blocked).
+		Where("messages.created_at > COALESCE(room_users.last_read_at, room_users.created_at)").
+		Where("messages.created_at > ?", since).
+		Preload("User").
+		Order("messages.created_at ASC").
+		Limit(maxItems).
+		Find(&direct).Error; err != nil {
+		return nil, err
+	}
+
+	var mentions []models.Mention
+	if err := database.DB.Joins("JOIN room_users ON room_users.room_id = mentions.room_id AND room_users.user_id = mentions.user_id").
+		Where("mentions.user_id = ? AND mentions.read_at IS NULL", userID).
+		Where("mentions.created_at > COALESCE(room_users.last_read_at, room_users.created_at)").
+		Where("mentions.created_at > ?", since).
+		Preload("Message").
+		Preload("Message.User").
+		Order("mentions.created_at ASC").
+		Limit(maxItems).
+		Find(&mentions).Error; err != nil {
+		return nil, err
+	}
+
+	// Look up room names once
+	roomIDs := make([]uint, 0, len(direct)+len(mentions))
+	for _, message := range direct {
+		roomIDs = append(roomIDs, message.RoomID)
+	}
+	for _, mention := range mentions {
+		roomIDs = append(roomIDs, mention.RoomID)
+	}
+	var rooms []models.Room
+	if len(roomIDs) > 0 {
+		if err := database.DB.Where("id IN ?", roomIDs).Find(&rooms).Error; err != nil {
+			return nil, err
+		}
+	}
+	roomNames := make(map[uint]string, len(rooms))
+	for _, room := range rooms {
+		roomNames[room.ID] = room.Name
+	}
+
+	var items []Item
+	seen := make(map[uint]bool)
+	for _, mention := range mentions {
+		if mention.Message == nil || !notifications.Allowed(userID, mention.RoomID, true) {
+			continue
+		}
+		seen[mention.MessageID] = true
+		items = append(items, item(*mention.Message, roomNames[mention.RoomID], true))
+	}
+	for _, message := range direct {
+		if seen[message.ID] || !notifications.Allowed(userID, message.RoomID, false) {
+			continue
+		}
+		items = append(items, item(message, roomNames[message.RoomID], false))
+	}
+
+	if len(items) > maxItems {
+		items = items[:maxItems]
+	}
+	return items, nil
+}
+
+// item converts a message into a digest entry
+func item(message models.Message, room string, mention bool) Item {
+	return Item{
+		Room:    room,
+		Sender:  message.Sender(),
+		Content: message.Content,
+		Time:    message.CreatedAt,
+		Mention: mention,
+	}
+}
+
+// render builds the digest email for a user
+func render(user models.User, items []Item) (mailer.Email, error) {
+	appURL := os.Getenv("APP_URL")
+	if appURL == "" {
+		appURL = "http://localhost:3000"
+	}
+
+	d := data{
+		Username: user.Username,
+		Count:    len(items),
+		Items:    items,
+		AppURL:   appURL,
+	}
+
+	var text, html bytes.Buffer
+	if err := textTemplate.Execute(&text, d); err != nil {
+		return mailer.Email{}, err
+	}
+	if err := htmlTemplate.Execute(&html, d); err != nil {
+		return mailer.Email{}, err
+	}
+
+	return mailer.Email{
+		To:      user.Email,
+		Subject: fmt.Sprintf("You have %d unread messages", len(items)),
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
+
+// period returns the minimum time between two digests at a frequency
+func period(frequency string) time.Duration {
+	if frequency == models.DigestWeekly {
+		return 7 * 24 * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// durationEnv reads a duration from the environment, using fallback when unset or invalid
+func durationEnv(key string, fallback time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(key)); err == nil && d > 0 {
+		return d
+	}
+	return fallback
+}
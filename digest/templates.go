@@ -0,0 +1,37 @@
+package digest
+
+import (
+	htmltemplate "html/template"
+	texttemplate "text/template"
+)
+
+var textTemplate = texttemplate.Must(texttemplate.New("digest").Parse(`Hi {{.Username}},
+
+You have {{.Count}} unread {{if eq .Count 1}}message{{else}}messages{{end}} waiting for you.
+{{range .Items}}
+[{{.Room}}] {{.Sender}}{{if .Mention}} mentioned you{{end}} at {{.Time.Format "Jan 2 15:04"}}:
+  {{.Content}}
+{{end}}
+Open the app to reply: {{.AppURL}}
+
+You can change how often you receive these emails in your notification settings.
+`))
+
+var htmlTemplate = htmltemplate.Must(htmltemplate.New("digest").Parse(`<!DOCTYPE html>
+<html>
+<body style="font-family: sans-serif; color: #222;">
+  <p>Hi {{.Username}},</p>
+  <p>You have {{.Count}} unread {{if eq .Count 1}}message{{else}}messages{{end}} waiting for you.</p>
+  {{range .Items}}
+  <div style="border-left: 3px solid {{if .Mention}}#e8a400{{else}}#4a7bd0{{end}}; padding: 4px 12px; margin: 12px 0;">
+    <div style="font-size: 12px; color: #666;">
+      {{.Room}} &middot; <strong>{{.Sender}}</strong>{{if .Mention}} mentioned you{{end}} &middot; {{.Time.Format "Jan 2 15:04"}}
+    </div>
+    <div>{{.Content}}</div>
+  </div>
+  {{end}}
+  <p><a href="{{.AppURL}}">Open the app to reply</a></p>
+  <p style="font-size: 12px; color: #888;">You can change how often you receive these emails in your notification settings.</p>
+</body>
+</html>
+`))
@@ -0,0 +1,30 @@
+// Package testdb connects tests that need Postgres to a scratch database
+package testdb
+
+import (
+	"os"
+	"testing"
+
+	"github.com/CUknot/network_backend/database"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Open points database.DB at the database named by TEST_DATABASE_DSN and
+// migrates it. Tests are skipped when the variable isn't set.
+func Open(t *testing.T) {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("connecting to test database: %v", err)
+	}
+	database.DB = db
+	database.Migrate()
+}
@@ -0,0 +1,127 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"mime"
+	"mime/quotedprintable"
+	"net/smtp"
+	"os"
+	"time"
+)
+
+// Email is a message with plain-text and HTML alternatives
+type Email struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer delivers emails
+type Mailer interface {
+	Send(email Email) error
+}
+
+// Default is the mailer used by the application
+var Default Mailer = LogMailer{}
+
+// Init selects the mailer named by MAIL_DRIVER ("smtp" or "log")
+func Init() {
+	switch os.Getenv("MAIL_DRIVER") {
+	case "smtp":
+		Default = SMTPMailer{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     getenv("SMTP_PORT", "587"),
+			Username: os.Getenv("SMTP_USER"),
+			Password: os.Getenv("SMTP_PASS"),
+			From:     getenv("MAIL_FROM", "no-reply@example.com"),
+		}
+	default:
+		Default = LogMailer{}
+	}
+}
+
+// LogMailer writes emails to the log instead of sending them
+type LogMailer struct{}
+
+// Send logs the email
+func (LogMailer) Send(email Email) error {
+	log.Printf("mail to %s: %s\n%s", email.To, email.Subject, email.Text)
+	return nil
+}
+
+// SMTPMailer sends emails through an SMTP server
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// Send delivers the email as a multipart/alternative message
+func (m SMTPMailer) Send(email Email) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	body, err := buildMessage(m.From, email)
+	if err != nil {
+		return err
+	}
+
+	return smtp.SendMail(m.Host+":"+m.Port, auth, m.From, []string{email.To}, body)
+}
+
+// buildMessage renders the email with headers and both body alternatives
+func buildMessage(from string, email Email) ([]byte, error) {
+	boundary := make([]byte, 16)
+	if _, err := rand.Read(boundary); err != nil {
+		return nil, err
+	}
+	b := hex.EncodeToString(boundary)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", email.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", email.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", b)
+
+	for _, part := range []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain", email.Text},
+		{"text/html", email.HTML},
+	} {
+		fmt.Fprintf(&buf, "--%s\r\n", b)
+		fmt.Fprintf(&buf, "Content-Type: %s; charset=UTF-8\r\n", part.contentType)
+		fmt.Fprintf(&buf, "Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		w := quotedprintable.NewWriter(&buf)
+		if _, err := w.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		buf.WriteString("\r\n")
+	}
+	fmt.Fprintf(&buf, "--%s--\r\n", b)
+
+	return buf.Bytes(), nil
+}
+
+// getenv returns an environment variable or a fallback when it is unset
+func getenv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
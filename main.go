@@ -6,7 +6,14 @@ import (
 
 	"github.com/CUknot/network_backend/controllers"
 	"github.com/CUknot/network_backend/database"
+	"github.com/CUknot/network_backend/digest"
+	"github.com/CUknot/network_backend/mailer"
 	"github.com/CUknot/network_backend/middleware"
+	"github.com/CUknot/network_backend/push"
+	"github.com/CUknot/network_backend/retention"
+	"github.com/CUknot/network_backend/safehttp"
+	"github.com/CUknot/network_backend/scheduler"
+	"github.com/CUknot/network_backend/webhooks"
 	"github.com/CUknot/network_backend/websocket"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -22,6 +29,34 @@ func main() {
 	database.Connect()
 	database.Migrate()
 
+	// Initialize login throttling
+	controllers.InitLoginThrottle()
+
+	// Load the keys used to sign push notifications
+	push.InitVAPID()
+
+	// Configure outgoing email
+	mailer.Init()
+
+	// Start emailing digests of missed messages
+	digest.Start()
+
+	// Configure requests to user-supplied URLs
+	safehttp.Init()
+
+	// Start delivering outgoing webhooks
+	webhooks.Start()
+
+	// Start sending scheduled messages
+	scheduler.Start()
+
+	// Start deleting messages past their retention period
+	retention.Start()
+
+	// Register slash commands and send socket messages through the same path as the API
+	controllers.RegisterCommands()
+	websocket.MessageHandler = controllers.HandleSocketMessage
+
 	// Set up router
 	router := gin.Default()
 
@@ -45,22 +80,110 @@ func main() {
 	{
 		auth.POST("/register", controllers.Register)
 		auth.POST("/login", controllers.Login)
+		auth.POST("/email/confirm", controllers.ConfirmEmailChange)
 	}
 
+	// Incoming webhooks authenticate with the token in their URL
+	router.POST("/api/hooks/:token", controllers.PostIncomingWebhook)
+
 	// Protected routes
 	api := router.Group("/api")
 	api.Use(middleware.JWTAuth())
 	{
+		// Profile routes
+		api.GET("/me", controllers.GetMe)
+		api.PUT("/me", controllers.UpdateMe)
+		api.DELETE("/me", controllers.DeleteMe)
+		api.PUT("/me/password", controllers.ChangePassword)
+		api.GET("/me/preferences", controllers.GetPreferences)
+		api.PUT("/me/preferences", controllers.UpdatePreferences)
+
+		// User routes
+		api.GET("/users/lookup", controllers.LookupUser)
+		api.GET("/users/search", controllers.SearchUsers)
+
+		// Friend routes
+		api.GET("/friends", controllers.GetFriends)
+		api.DELETE("/friends/:userId", controllers.RemoveFriend)
+		api.GET("/friends/requests", controllers.GetFriendRequests)
+		api.POST("/friends/requests", controllers.SendFriendRequest)
+		api.POST("/friends/requests/:id/accept", controllers.AcceptFriendRequest)
+		api.POST("/friends/requests/:id/decline", controllers.DeclineFriendRequest)
+		api.DELETE("/friends/requests/:id", controllers.CancelFriendRequest)
+
+		// Block routes
+		api.GET("/blocks", controllers.GetBlocks)
+		api.POST("/blocks/:userId", controllers.BlockUser)
+		api.DELETE("/blocks/:userId", controllers.UnblockUser)
+
+		// Push notification routes
+		api.GET("/push/vapid-public-key", controllers.GetVapidPublicKey)
+		api.POST("/push/subscriptions", controllers.CreatePushSubscription)
+		api.DELETE("/push/subscriptions", controllers.DeletePushSubscription)
+
+		// Session routes
+		api.GET("/sessions", controllers.GetSessions)
+		api.DELETE("/sessions", controllers.RevokeOtherSessions)
+		api.DELETE("/sessions/:id", controllers.RevokeSession)
+
 		// Room routes
 		api.GET("/rooms", controllers.GetRooms)
 		api.POST("/rooms", controllers.CreateRoom)
+		api.POST("/rooms/direct", controllers.CreateDirectRoom)
 		api.GET("/rooms/:id", controllers.GetRoom)
 		api.PUT("/rooms/:id", controllers.UpdateRoom)
 		api.DELETE("/rooms/:id", controllers.DeleteRoom)
+		api.POST("/rooms/:id/members", controllers.AddRoomMembers)
+		api.POST("/rooms/:id/read", controllers.MarkRoomRead)
+		api.PUT("/rooms/:id/notifications", controllers.UpdateRoomNotifications)
+		api.PUT("/rooms/:id/retention", controllers.UpdateRoomRetention)
+		api.PUT("/rooms/:id/members/:userId/role", controllers.UpdateMemberRole)
+		api.GET("/rooms/:id/pins", controllers.GetPins)
+		api.POST("/rooms/:id/pins", controllers.PinMessage)
+		api.DELETE("/rooms/:id/pins/:messageId", controllers.UnpinMessage)
+
+		// Webhook routes
+		api.GET("/rooms/:id/webhooks", controllers.GetWebhooks)
+		api.POST("/rooms/:id/webhooks", controllers.CreateWebhook)
+		api.PUT("/rooms/:id/webhooks/:webhookId", controllers.UpdateWebhook)
+		api.DELETE("/rooms/:id/webhooks/:webhookId", controllers.DeleteWebhook)
+		api.GET("/rooms/:id/webhooks/:webhookId/deliveries", controllers.GetWebhookDeliveries)
+		api.GET("/rooms/:id/incoming-webhooks", controllers.GetIncomingWebhooks)
+		api.POST("/rooms/:id/incoming-webhooks", controllers.CreateIncomingWebhook)
+		api.DELETE("/rooms/:id/incoming-webhooks/:webhookId", controllers.DeleteIncomingWebhook)
+
+		// Bot command routes
+		api.GET("/rooms/:id/commands", controllers.GetBotCommands)
+		api.POST("/rooms/:id/commands", controllers.CreateBotCommand)
+		api.DELETE("/rooms/:id/commands/:commandId", controllers.DeleteBotCommand)
 
 		// Message routes
 		api.GET("/messages", controllers.GetMessages)
 		api.POST("/messages", controllers.CreateMessage)
+		api.PUT("/messages/:id", controllers.UpdateMessage)
+		api.DELETE("/messages/:id", controllers.DeleteMessage)
+		api.POST("/messages/:id/forward", controllers.ForwardMessage)
+
+		// Poll routes
+		api.POST("/polls", controllers.CreatePoll)
+		api.POST("/polls/:id/votes", controllers.VotePoll)
+		api.DELETE("/polls/:id/votes", controllers.RetractVote)
+		api.POST("/polls/:id/close", controllers.ClosePoll)
+
+		// Scheduled message routes
+		api.GET("/scheduled-messages", controllers.GetScheduledMessages)
+		api.PUT("/scheduled-messages/:id", controllers.UpdateScheduledMessage)
+		api.DELETE("/scheduled-messages/:id", controllers.CancelScheduledMessage)
+
+		// Bookmark routes
+		api.GET("/bookmarks", controllers.GetBookmarks)
+		api.POST("/bookmarks", controllers.CreateBookmark)
+		api.PUT("/bookmarks/:id", controllers.UpdateBookmark)
+		api.DELETE("/bookmarks/:id", controllers.DeleteBookmark)
+
+		// Mention routes
+		api.GET("/mentions", controllers.GetMentions)
+		api.POST("/mentions/read", controllers.MarkMentionsRead)
 	}
 
 	// WebSocket route
@@ -0,0 +1,109 @@
+package markdown
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+)
+
+// Version of the AST format, bumped when node types change meaning
+const Version = 1
+
+// Block node types
+const (
+	NodeParagraph = "paragraph"
+	NodeCodeBlock = "code_block"
+)
+
+// Inline node types
+const (
+	NodeText      = "text"
+	NodeBold      = "bold"
+	NodeItalic    = "italic"
+	NodeCode      = "code"
+	NodeLink      = "link"
+	NodeMention   = "mention"
+	NodeEmoji     = "emoji"
+	NodeLineBreak = "line_break"
+)
+
+// Node is an element of a parsed message. Text is always plain text; clients
+// must never interpret it as HTML.
+type Node struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	URL      string `json:"url,omitempty"`
+	Language string `json:"language,omitempty"`
+	Handle   string `json:"handle,omitempty"`
+	UserID   uint   `json:"user_id,omitempty"`
+	Emoji    string `json:"emoji,omitempty"`
+	Children []Node `json:"children,omitempty"`
+}
+
+// Document is a parsed message. It is stored as jsonb.
+type Document struct {
+	Version int    `json:"version"`
+	Blocks  []Node `json:"blocks"`
+}
+
+// Value implements driver.Valuer
+func (d Document) Value() (driver.Value, error) {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan implements sql.Scanner
+func (d *Document) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*d = Document{}
+		return nil
+	case []byte:
+		return json.Unmarshal(v, d)
+	case string:
+		return json.Unmarshal([]byte(v), d)
+	}
+	return errors.New("unsupported type for markdown.Document")
+}
+
+// Links returns the URLs of every link in the document, in order
+func (d *Document) Links() []string {
+	var links []string
+	walk(d.Blocks, func(node *Node) {
+		if node.Type == NodeLink {
+			links = append(links, node.URL)
+		}
+	})
+	return links
+}
+
+// Mentions returns the handles of every mention in the document, in order
+func (d *Document) Mentions() []string {
+	var handles []string
+	walk(d.Blocks, func(node *Node) {
+		if node.Type == NodeMention {
+			handles = append(handles, node.Handle)
+		}
+	})
+	return handles
+}
+
+// ResolveMentions fills in the user ID of each mention whose handle is in userIDs
+func (d *Document) ResolveMentions(userIDs map[string]uint) {
+	walk(d.Blocks, func(node *Node) {
+		if node.Type == NodeMention {
+			node.UserID = userIDs[node.Handle]
+		}
+	})
+}
+
+// walk calls fn for every node in a tree, parents before children
+func walk(nodes []Node, fn func(node *Node)) {
+	for i := range nodes {
+		fn(&nodes[i])
+		walk(nodes[i].Children, fn)
+	}
+}
@@ -0,0 +1,57 @@
+package markdown
+
+// Supported emoji shortcodes. Unknown shortcodes are left as text.
+var emojis = map[string]string{
+	"+1":                     "👍",
+	"-1":                     "👎",
+	"100":                    "💯",
+	"angry":                  "😠",
+	"blush":                  "😊",
+	"bulb":                   "💡",
+	"check":                  "✔️",
+	"clap":                   "👏",
+	"coffee":                 "☕",
+	"confused":               "😕",
+	"cry":                    "😢",
+	"eyes":                   "👀",
+	"fire":                   "🔥",
+	"grin":                   "😁",
+	"grinning":               "😀",
+	"heart":                  "❤️",
+	"heart_eyes":             "😍",
+	"hugs":                   "🤗",
+	"joy":                    "😂",
+	"laughing":               "😆",
+	"muscle":                 "💪",
+	"ok_hand":                "👌",
+	"party":                  "🥳",
+	"pray":                   "🙏",
+	"rocket":                 "🚀",
+	"rofl":                   "🤣",
+	"scream":                 "😱",
+	"see_no_evil":            "🙈",
+	"slightly_smiling":       "🙂",
+	"smile":                  "😄",
+	"smiley":                 "😃",
+	"sob":                    "😭",
+	"sparkles":               "✨",
+	"star":                   "⭐",
+	"sunglasses":             "😎",
+	"tada":                   "🎉",
+	"thinking":               "🤔",
+	"thumbsdown":             "👎",
+	"thumbsup":               "👍",
+	"warning":                "⚠️",
+	"wave":                   "👋",
+	"white_check_mark":       "✅",
+	"wink":                   "😉",
+	"x":                      "❌",
+	"zzz":                    "💤",
+	"upside_down_face":       "🙃",
+	"sweat_smile":            "😅",
+	"raised_hands":           "🙌",
+	"heavy_check_mark":       "✔️",
+	"point_up":               "☝️",
+	"stuck_out_tongue":       "😛",
+	"face_with_rolling_eyes": "🙄",
+}
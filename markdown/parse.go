@@ -0,0 +1,314 @@
+package markdown
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// Content longer than this is kept as a single plain text paragraph
+	maxParseLength = 20000
+
+	// Deepest nesting of bold, italic, and link nodes
+	maxDepth = 8
+)
+
+// MentionPattern matches @username#tag, @here, and @room
+var MentionPattern = regexp.MustCompile(`^@([^\s@#]+)(?:#(\S{4}))?`)
+
+// Matches a bare http or https URL
+var autolinkPattern = regexp.MustCompile(`^https?://[^\s<>"]+`)
+
+// Matches an emoji shortcode such as :smile:
+var shortcodePattern = regexp.MustCompile(`^:([a-z0-9_+\-]+):`)
+
+// Schemes a link may use
+var allowedSchemes = map[string]bool{"http": true, "https": true, "mailto": true}
+
+// Parse parses message content written in the supported Markdown subset:
+// **bold**, *italic* or _italic_, `code`, fenced code blocks, [links](url),
+// bare URLs, @mentions, and :emoji: shortcodes. Anything it does not
+// recognise, including HTML, is kept as plain text.
+func Parse(content string) Document {
+	content = sanitize(content)
+	doc := Document{Version: Version, Blocks: []Node{}}
+	if content == "" {
+		return doc
+	}
+	if utf8.RuneCountInString(content) > maxParseLength {
+		doc.Blocks = append(doc.Blocks, Node{Type: NodeParagraph, Children: []Node{{Type: NodeText, Text: content}}})
+		return doc
+	}
+
+	lines := strings.Split(content, "\n")
+	var paragraph []string
+	flush := func() {
+		if len(paragraph) > 0 {
+			doc.Blocks = append(doc.Blocks, Node{
+				Type:     NodeParagraph,
+				Children: parseInline(strings.Join(paragraph, "\n"), 0),
+			})
+			paragraph = nil
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		// Fenced code blocks run to the closing fence or the end of the message
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			flush()
+			language := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "```"))
+			var code []string
+			for i++; i < len(lines) && strings.TrimSpace(lines[i]) != "```"; i++ {
+				code = append(code, lines[i])
+			}
+			doc.Blocks = append(doc.Blocks, Node{
+				Type:     NodeCodeBlock,
+				Language: sanitizeLanguage(language),
+				Text:     strings.Join(code, "\n"),
+			})
+			continue
+		}
+
+		// Blank lines separate paragraphs
+		if strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+		paragraph = append(paragraph, line)
+	}
+	flush()
+
+	return doc
+}
+
+// parseInline parses the inline elements of a paragraph or of a formatted span
+func parseInline(text string, depth int) []Node {
+	var nodes []Node
+	var plain strings.Builder
+	emit := func(node Node) {
+		if plain.Len() > 0 {
+			nodes = append(nodes, Node{Type: NodeText, Text: plain.String()})
+			plain.Reset()
+		}
+		nodes = append(nodes, node)
+	}
+
+	for i := 0; i < len(text); {
+		rest := text[i:]
+		prev, _ := utf8.DecodeLastRuneInString(text[:i])
+		atWordStart := i == 0 || !isWordRune(prev)
+
+		switch {
+		case rest[0] == '\\' && len(rest) > 1 && isEscapable(rest[1]):
+			plain.WriteByte(rest[1])
+			i += 2
+			continue
+
+		case rest[0] == '\n':
+			emit(Node{Type: NodeLineBreak})
+			i++
+			continue
+
+		case rest[0] == '`':
+			if end := strings.IndexByte(rest[1:], '`'); end > 0 {
+				emit(Node{Type: NodeCode, Text: rest[1 : end+1]})
+				i += end + 2
+				continue
+			}
+
+		case depth < maxDepth && (strings.HasPrefix(rest, "**") || strings.HasPrefix(rest, "__")):
+			if end := strings.Index(rest[2:], rest[:2]); end > 0 {
+				emit(Node{Type: NodeBold, Children: parseInline(rest[2:end+2], depth+1)})
+				i += end + 4
+				continue
+			}
+
+		case depth < maxDepth && (rest[0] == '*' || (rest[0] == '_' && atWordStart)):
+			if end := closingDelimiter(rest[1:], rest[0]); end > 0 {
+				emit(Node{Type: NodeItalic, Children: parseInline(rest[1:end+1], depth+1)})
+				i += end + 2
+				continue
+			}
+
+		case depth < maxDepth && rest[0] == '[':
+			if label, target, n, ok := parseLink(rest); ok {
+				if href, safe := safeURL(target); safe {
+					emit(Node{Type: NodeLink, URL: href, Children: parseInline(label, maxDepth)})
+				} else {
+					plain.WriteString(rest[:n])
+				}
+				i += n
+				continue
+			}
+
+		case atWordStart && (rest[0] == 'h' || rest[0] == 'H'):
+			if match := autolinkPattern.FindString(rest); match != "" {
+				match = trimTrailingPunctuation(match)
+				if href, safe := safeURL(match); safe {
+					emit(Node{Type: NodeLink, URL: href, Children: []Node{{Type: NodeText, Text: match}}})
+					i += len(match)
+					continue
+				}
+			}
+
+		case atWordStart && rest[0] == '@':
+			if match := MentionPattern.FindStringSubmatch(rest); match != nil && trimMentionPunctuation(match) {
+				emit(Node{Type: NodeMention, Text: match[0], Handle: mentionHandle(match)})
+				i += len(match[0])
+				continue
+			}
+
+		case rest[0] == ':':
+			if match := shortcodePattern.FindStringSubmatch(rest); match != nil {
+				if emoji, ok := emojis[match[1]]; ok {
+					emit(Node{Type: NodeEmoji, Text: match[0], Emoji: emoji})
+					i += len(match[0])
+					continue
+				}
+			}
+		}
+
+		_, size := utf8.DecodeRuneInString(rest)
+		plain.WriteString(rest[:size])
+		i += size
+	}
+
+	if plain.Len() > 0 {
+		nodes = append(nodes, Node{Type: NodeText, Text: plain.String()})
+	}
+	return nodes
+}
+
+// closingDelimiter finds the delimiter closing an italic span. An underscore
+// only closes at the end of a word so snake_case names are left alone.
+func closingDelimiter(text string, delimiter byte) int {
+	for i := 0; i < len(text); i++ {
+		if text[i] != delimiter || i == 0 || text[i-1] == ' ' {
+			continue
+		}
+		if delimiter == '_' && i+1 < len(text) {
+			next, _ := utf8.DecodeRuneInString(text[i+1:])
+			if isWordRune(next) {
+				continue
+			}
+		}
+		return i
+	}
+	return -1
+}
+
+// parseLink reads [label](target) from the start of text and returns its parts and length
+func parseLink(text string) (string, string, int, bool) {
+	closeLabel := strings.Index(text, "](")
+	if closeLabel < 1 || strings.ContainsAny(text[1:closeLabel], "[\n") {
+		return "", "", 0, false
+	}
+	closeTarget := strings.IndexByte(text[closeLabel+2:], ')')
+	if closeTarget < 1 {
+		return "", "", 0, false
+	}
+	target := text[closeLabel+2 : closeLabel+2+closeTarget]
+	if strings.ContainsAny(target, " \n") {
+		return "", "", 0, false
+	}
+	return text[1:closeLabel], target, closeLabel + 3 + closeTarget, true
+}
+
+// safeURL checks that a link target is an absolute URL with an allowed scheme
+// and returns it normalised
+func safeURL(target string) (string, bool) {
+	u, err := url.Parse(target)
+	if err != nil || !allowedSchemes[strings.ToLower(u.Scheme)] {
+		return "", false
+	}
+	if u.Scheme != "mailto" && u.Host == "" {
+		return "", false
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	return u.String(), true
+}
+
+// WebURL checks that target is an absolute http or https URL, by the same rules
+// as links in messages, and returns it normalised
+func WebURL(target string) (string, bool) {
+	u, ok := safeURL(target)
+	if !ok || strings.HasPrefix(u, "mailto:") {
+		return "", false
+	}
+	return u, true
+}
+
+// mentionHandle returns the handle of a mention match: username#tag, here, or room
+func mentionHandle(match []string) string {
+	if match[2] == "" {
+		return match[1]
+	}
+	return match[1] + "#" + match[2]
+}
+
+// trimMentionPunctuation drops sentence punctuation that follows a mention
+// without a tag, so "@here," mentions here. It reports whether a handle is left.
+func trimMentionPunctuation(match []string) bool {
+	if match[2] != "" {
+		return true
+	}
+	username := strings.TrimRight(match[1], ".,;:!?)'\"")
+	match[0] = match[0][:len(match[0])-len(match[1])+len(username)]
+	match[1] = username
+	return username != ""
+}
+
+// trimTrailingPunctuation drops sentence punctuation that follows a bare URL
+func trimTrailingPunctuation(link string) string {
+	for len(link) > 0 && strings.ContainsRune(".,;:!?)'\"", rune(link[len(link)-1])) {
+		// Keep a closing parenthesis that balances one in the URL
+		if link[len(link)-1] == ')' && strings.Count(link, "(") >= strings.Count(link, ")") {
+			break
+		}
+		link = link[:len(link)-1]
+	}
+	return link
+}
+
+// sanitize normalises line endings and removes control characters other than
+// newlines and tabs
+func sanitize(content string) string {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	return strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' {
+			return r
+		}
+		if unicode.IsControl(r) || r == utf8.RuneError {
+			return -1
+		}
+		return r
+	}, content)
+}
+
+// sanitizeLanguage keeps a code block's language only if it is a plain identifier
+func sanitizeLanguage(language string) string {
+	if len(language) > 32 {
+		return ""
+	}
+	for _, r := range language {
+		if !isWordRune(r) && !strings.ContainsRune("+-#.", r) {
+			return ""
+		}
+	}
+	return language
+}
+
+// isWordRune reports whether r can be part of a word
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// isEscapable reports whether a backslash before c makes it literal
+func isEscapable(c byte) bool {
+	return strings.IndexByte("\\`*_[]()@:#", c) >= 0
+}
@@ -0,0 +1,152 @@
+package markdown
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func paragraph(children ...Node) Node {
+	return Node{Type: NodeParagraph, Children: children}
+}
+
+func text(s string) Node {
+	return Node{Type: NodeText, Text: s}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []Node
+	}{
+		{"empty", "", []Node{}},
+		{"plain text", "hello", []Node{paragraph(text("hello"))}},
+		{"line break", "a\nb", []Node{paragraph(text("a"), Node{Type: NodeLineBreak}, text("b"))}},
+		{"paragraphs", "a\n\nb", []Node{paragraph(text("a")), paragraph(text("b"))}},
+		{"control characters", "a\x00b\r\nc", []Node{paragraph(text("ab"), Node{Type: NodeLineBreak}, text("c"))}},
+
+		// Links
+		{"link", "[docs](https://example.com/a)", []Node{paragraph(
+			Node{Type: NodeLink, URL: "https://example.com/a", Children: []Node{text("docs")}},
+		)}},
+		{"mixed-case scheme is normalised", "[docs](HTTPS://example.com)", []Node{paragraph(
+			Node{Type: NodeLink, URL: "https://example.com", Children: []Node{text("docs")}},
+		)}},
+		{"mailto link", "[mail](mailto:someone@example.com)", []Node{paragraph(
+			Node{Type: NodeLink, URL: "mailto:someone@example.com", Children: []Node{text("mail")}},
+		)}},
+		{"javascript link", "[click](javascript:alert(1))", []Node{paragraph(text("[click](javascript:alert(1))"))}},
+		{"mixed-case javascript link", "[click](JaVaScRiPt:alert`1`)", []Node{paragraph(text("[click](JaVaScRiPt:alert`1`)"))}},
+		{"data link", "[click](data:text/html,hi)", []Node{paragraph(text("[click](data:text/html,hi)"))}},
+		{"relative link", "[click](/settings)", []Node{paragraph(text("[click](/settings)"))}},
+		{"bare javascript url", "javascript:alert(1)", []Node{paragraph(text("javascript:alert(1)"))}},
+		{"bare url", "see https://example.com.", []Node{paragraph(
+			text("see "),
+			Node{Type: NodeLink, URL: "https://example.com", Children: []Node{text("https://example.com")}},
+			text("."),
+		)}},
+
+		// HTML is never interpreted
+		{"script tag", "<script>alert(1)</script>", []Node{paragraph(text("<script>alert(1)</script>"))}},
+		{"html link", `<a href="javascript:alert(1)">x</a>`, []Node{paragraph(text(`<a href="javascript:alert(1)">x</a>`))}},
+		{"html image", `<img src=x onerror=alert(1)>`, []Node{paragraph(text(`<img src=x onerror=alert(1)>`))}},
+
+		// Emphasis
+		{"bold", "**hi**", []Node{paragraph(Node{Type: NodeBold, Children: []Node{text("hi")}})}},
+		{"italic", "*hi*", []Node{paragraph(Node{Type: NodeItalic, Children: []Node{text("hi")}})}},
+		{"italic inside bold", "**bold _italic_ text**", []Node{paragraph(Node{Type: NodeBold, Children: []Node{
+			text("bold "),
+			Node{Type: NodeItalic, Children: []Node{text("italic")}},
+			text(" text"),
+		}})}},
+		{"bold inside italic", "_it **bold**_", []Node{paragraph(Node{Type: NodeItalic, Children: []Node{
+			text("it "),
+			Node{Type: NodeBold, Children: []Node{text("bold")}},
+		}})}},
+		{"unclosed emphasis", "**hi", []Node{paragraph(text("**hi"))}},
+		{"snake_case", "call snake_case_name now", []Node{paragraph(text("call snake_case_name now"))}},
+		{"snake_case inside italic", "_snake_case_", []Node{paragraph(Node{Type: NodeItalic, Children: []Node{text("snake_case")}})}},
+
+		// Escapes
+		{"escaped asterisks", `\*not italic\*`, []Node{paragraph(text("*not italic*"))}},
+		{"escaped underscores", `\_not italic\_`, []Node{paragraph(text("_not italic_"))}},
+		{"escaped link", `\[a](https://example.com)`, []Node{paragraph(
+			text("[a]("),
+			Node{Type: NodeLink, URL: "https://example.com", Children: []Node{text("https://example.com")}},
+			text(")"),
+		)}},
+		{"escaped mention", `\@alice`, []Node{paragraph(text("@alice"))}},
+		{"escaped backslash", `\\*hi*`, []Node{paragraph(text(`\`), Node{Type: NodeItalic, Children: []Node{text("hi")}})}},
+
+		// Code
+		{"code span", "`**x**`", []Node{paragraph(Node{Type: NodeCode, Text: "**x**"})}},
+		{"fenced code", "before\n```go\nfmt.Println(\"**x**\")\n<b>\n```\nafter", []Node{
+			paragraph(text("before")),
+			{Type: NodeCodeBlock, Language: "go", Text: "fmt.Println(\"**x**\")\n<b>"},
+			paragraph(text("after")),
+		}},
+		{"unclosed fence", "```\n*a*\n\nb", []Node{{Type: NodeCodeBlock, Text: "*a*\n\nb"}}},
+		{"unsafe fence language", "```<script>\nx\n```", []Node{{Type: NodeCodeBlock, Text: "x"}}},
+
+		// Mentions and emoji
+		{"mentions", "hi @alice#1234 and @here", []Node{paragraph(
+			text("hi "),
+			Node{Type: NodeMention, Text: "@alice#1234", Handle: "alice#1234"},
+			text(" and "),
+			Node{Type: NodeMention, Text: "@here", Handle: "here"},
+		)}},
+		{"mentions before punctuation", "@here, @room. @room! @alice: (@bob)", []Node{paragraph(
+			Node{Type: NodeMention, Text: "@here", Handle: "here"},
+			text(", "),
+			Node{Type: NodeMention, Text: "@room", Handle: "room"},
+			text(". "),
+			Node{Type: NodeMention, Text: "@room", Handle: "room"},
+			text("! "),
+			Node{Type: NodeMention, Text: "@alice", Handle: "alice"},
+			text(": ("),
+			Node{Type: NodeMention, Text: "@bob", Handle: "bob"},
+			text(")"),
+		)}},
+		{"tagged mention before punctuation", "@alice#1234.", []Node{paragraph(
+			Node{Type: NodeMention, Text: "@alice#1234", Handle: "alice#1234"},
+			text("."),
+		)}},
+		{"punctuation only", "@!?", []Node{paragraph(text("@!?"))}},
+		{"email is not a mention", "a@example.com", []Node{paragraph(text("a@example.com"))}},
+		{"emoji", ":smile: :no_such_emoji:", []Node{paragraph(
+			Node{Type: NodeEmoji, Text: ":smile:", Emoji: "😄"},
+			text(" :no_such_emoji:"),
+		)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := Parse(tt.content)
+			if doc.Version != Version {
+				t.Errorf("Version = %d, want %d", doc.Version, Version)
+			}
+			if !reflect.DeepEqual(doc.Blocks, tt.want) {
+				t.Errorf("Parse(%q) =\n%+v\nwant\n%+v", tt.content, doc.Blocks, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLongContent(t *testing.T) {
+	content := "**" + strings.Repeat("a", maxParseLength) + "**"
+	want := []Node{paragraph(text(content))}
+	if got := Parse(content).Blocks; !reflect.DeepEqual(got, want) {
+		t.Errorf("long content was parsed, want a single text paragraph")
+	}
+}
+
+func TestDocumentLinksAndMentions(t *testing.T) {
+	doc := Parse("@bob see [a](https://a.example) and **https://b.example @room**")
+	if got, want := doc.Links(), []string{"https://a.example", "https://b.example"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Links = %v, want %v", got, want)
+	}
+	if got, want := doc.Mentions(), []string{"bob", "room"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Mentions = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,53 @@
+package messaging
+
+import (
+	"unicode/utf8"
+
+	"github.com/CUknot/network_backend/markdown"
+	"github.com/CUknot/network_backend/models"
+)
+
+const (
+	// Limits on attachments sent by webhooks and bots
+	maxAttachmentTitle  = 256
+	maxAttachmentText   = 4000
+	maxAttachmentFields = 20
+	maxAttachmentColor  = 16
+)
+
+// SanitizeAttachments clears any link or image that is not an absolute http or
+// https URL and shortens text fields to their limits, so attachments from
+// webhooks and bots are as safe to render as message content
+func SanitizeAttachments(attachments []models.Attachment) []models.Attachment {
+	for i := range attachments {
+		a := &attachments[i]
+		for _, link := range []*string{&a.AuthorLink, &a.AuthorIcon, &a.TitleLink, &a.ImageURL, &a.ThumbURL, &a.FooterIcon} {
+			*link, _ = markdown.WebURL(*link)
+		}
+
+		a.Color = clip(a.Color, maxAttachmentColor)
+		a.AuthorName = clip(a.AuthorName, maxAttachmentTitle)
+		a.Title = clip(a.Title, maxAttachmentTitle)
+		a.Footer = clip(a.Footer, maxAttachmentTitle)
+		a.Fallback = clip(a.Fallback, maxAttachmentText)
+		a.Pretext = clip(a.Pretext, maxAttachmentText)
+		a.Text = clip(a.Text, maxAttachmentText)
+
+		if len(a.Fields) > maxAttachmentFields {
+			a.Fields = a.Fields[:maxAttachmentFields]
+		}
+		for j := range a.Fields {
+			a.Fields[j].Title = clip(a.Fields[j].Title, maxAttachmentTitle)
+			a.Fields[j].Value = clip(a.Fields[j].Value, maxAttachmentText)
+		}
+	}
+	return attachments
+}
+
+// clip shortens text to at most n runes
+func clip(text string, n int) string {
+	if utf8.RuneCountInString(text) <= n {
+		return text
+	}
+	return string([]rune(text)[:n])
+}
@@ -0,0 +1,93 @@
+package messaging
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/CUknot/network_backend/models"
+)
+
+func TestSanitizeAttachmentLinks(t *testing.T) {
+	tests := []struct {
+		link string
+		want string
+	}{
+		{"https://example.com/a?b=c", "https://example.com/a?b=c"},
+		{"HTTP://example.com", "http://example.com"},
+		{"javascript:alert(1)", ""},
+		{"JavaScript:alert(1)", ""},
+		{"data:text/html;base64,PHNjcmlwdD4=", ""},
+		{"mailto:someone@example.com", ""},
+		{"//example.com/image.png", ""},
+		{"/relative/path", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		got := SanitizeAttachments([]models.Attachment{{
+			AuthorLink: tt.link,
+			AuthorIcon: tt.link,
+			TitleLink:  tt.link,
+			ImageURL:   tt.link,
+			ThumbURL:   tt.link,
+			FooterIcon: tt.link,
+		}})[0]
+		for name, link := range map[string]string{
+			"author_link": got.AuthorLink,
+			"author_icon": got.AuthorIcon,
+			"title_link":  got.TitleLink,
+			"image_url":   got.ImageURL,
+			"thumb_url":   got.ThumbURL,
+			"footer_icon": got.FooterIcon,
+		} {
+			if link != tt.want {
+				t.Errorf("%s for %q = %q, want %q", name, tt.link, link, tt.want)
+			}
+		}
+	}
+}
+
+func TestSanitizeAttachmentLimits(t *testing.T) {
+	long := strings.Repeat("é", maxAttachmentText+10)
+	fields := make([]models.AttachmentField, maxAttachmentFields+5)
+	for i := range fields {
+		fields[i] = models.AttachmentField{Title: long, Value: long}
+	}
+
+	got := SanitizeAttachments([]models.Attachment{{
+		Fallback:   long,
+		Color:      long,
+		Pretext:    long,
+		AuthorName: long,
+		Title:      long,
+		Text:       long,
+		Footer:     long,
+		Fields:     fields,
+	}})[0]
+
+	limits := map[string]struct {
+		text  string
+		limit int
+	}{
+		"fallback":    {got.Fallback, maxAttachmentText},
+		"color":       {got.Color, maxAttachmentColor},
+		"pretext":     {got.Pretext, maxAttachmentText},
+		"author_name": {got.AuthorName, maxAttachmentTitle},
+		"title":       {got.Title, maxAttachmentTitle},
+		"text":        {got.Text, maxAttachmentText},
+		"footer":      {got.Footer, maxAttachmentTitle},
+		"field title": {got.Fields[0].Title, maxAttachmentTitle},
+		"field value": {got.Fields[0].Value, maxAttachmentText},
+	}
+	for name, field := range limits {
+		if n := utf8.RuneCountInString(field.text); n != field.limit {
+			t.Errorf("%s has %d runes, want %d", name, n, field.limit)
+		}
+		if !utf8.ValidString(field.text) {
+			t.Errorf("%s is not valid UTF-8 after clipping", name)
+		}
+	}
+	if len(got.Fields) != maxAttachmentFields {
+		t.Errorf("kept %d fields, want %d", len(got.Fields), maxAttachmentFields)
+	}
+}
@@ -0,0 +1,142 @@
+package messaging
+
+import (
+	"time"
+
+	"github.com/CUknot/network_backend/database"
+	"github.com/CUknot/network_backend/models"
+	"github.com/CUknot/network_backend/webhooks"
+	"github.com/CUknot/network_backend/websocket"
+	"gorm.io/gorm"
+)
+
+// DeletedEvent is the payload broadcast when a message is deleted
+type DeletedEvent struct {
+	ID     uint `json:"id"`
+	RoomID uint `json:"room_id"`
+}
+
+// Edit replaces a message's content, brings its mentions up to date, and
+// publishes the change to its room
+func Edit(message *models.Message, content string) error {
+	now := time.Now()
+	message.Content = content
+	message.EditedAt = &now
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		mentions, err := parse(tx, message)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.Message{}).Where("id = ?", message.ID).Updates(map[string]interface{}{
+			"content":   content,
+			"body":      message.Body,
+			"edited_at": now,
+		}).Error; err != nil {
+			return err
+		}
+
+		// Keep existing mentions so their read state survives the edit
+		var existing []models.Mention
+		if err := tx.Where("message_id = ?", message.ID).Find(&existing).Error; err != nil {
+			return err
+		}
+		current := make(map[uint]bool, len(existing))
+		for _, mention := range existing {
+			current[mention.UserID] = true
+		}
+		wanted := make(map[uint]bool, len(mentions))
+		var added []models.Mention
+		for _, mention := range mentions {
+			wanted[mention.UserID] = true
+			if !current[mention.UserID] {
+				added = append(added, mention)
+			}
+		}
+		for _, mention := range existing {
+			if wanted[mention.UserID] {
+				continue
+			}
+			if err := tx.Delete(&mention).Error; err != nil {
+				return err
+			}
+		}
+		if len(added) > 0 {
+			return tx.Create(&added).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Mentions are left out since their read state is only for the mentioned user
+	message.Mentions = nil
+	database.DB.Preload("User").First(message, message.ID)
+	Broadcast("message_updated", message)
+	webhooks.Dispatch(message.RoomID, models.EventMessageEdited, webhooks.MessageData(*message))
+
+	// Refresh link previews for the new content
+	go unfurlMessage(*message)
+	return nil
+}
+
+// Delete removes messages together with the records that belong to them and
+// tells their rooms they are gone
+func Delete(messages []models.Message) error {
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		return Remove(tx, messages)
+	}); err != nil {
+		return err
+	}
+
+	Announce(messages)
+	return nil
+}
+
+// Remove deletes messages and the records that belong to them
+func Remove(tx *gorm.DB, messages []models.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, len(messages))
+	for i, message := range messages {
+		ids[i] = message.ID
+	}
+
+	if err := tx.Where("message_id IN ?", ids).Delete(&models.Mention{}).Error; err != nil {
+		return err
+	}
+	// Quotes of removed messages keep the reference but lose the copied content
+	if err := tx.Model(&models.Message{}).Where("quoted_message_id IN ?", ids).Update("quote", nil).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("message_id IN ?", ids).Delete(&models.Pin{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("message_id IN ?", ids).Delete(&models.Bookmark{}).Error; err != nil {
+		return err
+	}
+	pollIDs := tx.Model(&models.Poll{}).Select("id").Where("message_id IN ?", ids)
+	if err := tx.Where("poll_id IN (?)", pollIDs).Delete(&models.PollVote{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("poll_id IN (?)", pollIDs).Delete(&models.PollOption{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("message_id IN ?", ids).Delete(&models.Poll{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("id IN ?", ids).Delete(&models.Message{}).Error
+}
+
+// Announce tells the rooms of removed messages that they are gone
+func Announce(messages []models.Message) {
+	for _, message := range messages {
+		event := DeletedEvent{ID: message.ID, RoomID: message.RoomID}
+		websocket.BroadcastToRoom(message.RoomID, "message_deleted", event)
+		webhooks.Dispatch(message.RoomID, models.EventMessageDeleted, map[string]interface{}{"message": event})
+	}
+}
@@ -0,0 +1,90 @@
+package messaging
+
+import (
+	"github.com/CUknot/network_backend/models"
+	"github.com/CUknot/network_backend/websocket"
+	"gorm.io/gorm"
+)
+
+// resolveMentions finds the room members mentioned in a message's parsed body.
+// It also returns the user ID each username#tag handle refers to.
+func resolveMentions(tx *gorm.DB, message *models.Message) ([]models.Mention, map[string]uint, error) {
+	if message.Body == nil {
+		return nil, nil, nil
+	}
+	handles := message.Body.Mentions()
+	if len(handles) == 0 {
+		return nil, nil, nil
+	}
+
+	// Everyone who could be mentioned: members other than the author who have not blocked them
+	var memberIDs []uint
+	if err := tx.Model(&models.RoomUser{}).
+		Where("room_id = ? AND user_id != ?", message.RoomID, message.UserID).
+		Where("user_id NOT IN (?)", tx.Model(&models.Block{}).Select("blocker_id").Where("blocked_id = ?", message.UserID)).
+		Pluck("user_id", &memberIDs).Error; err != nil {
+		return nil, nil, err
+	}
+	members := make(map[uint]bool, len(memberIDs))
+	for _, id := range memberIDs {
+		members[id] = true
+	}
+
+	// Each user gets one mention per message, preferring the most specific kind
+	kinds := make(map[uint]string)
+	add := func(userID uint, kind string) {
+		if !members[userID] {
+			return
+		}
+		if current, ok := kinds[userID]; !ok || mentionRank[kind] > mentionRank[current] {
+			kinds[userID] = kind
+		}
+	}
+
+	userIDs := make(map[string]uint)
+	for _, handle := range handles {
+		switch handle {
+		case models.MentionRoom:
+			for _, id := range memberIDs {
+				add(id, models.MentionRoom)
+			}
+		case models.MentionHere:
+			for _, id := range memberIDs {
+				if websocket.IsOnline(id) {
+					add(id, models.MentionHere)
+				}
+			}
+		default:
+			name, tag, err := models.ParseHandle(handle)
+			if err != nil {
+				continue
+			}
+			var user models.User
+			if err := tx.Where("username = ? AND tag = ? AND deleted_at IS NULL", name, tag).Limit(1).Find(&user).Error; err != nil {
+				return nil, nil, err
+			}
+			if members[user.ID] {
+				userIDs[handle] = user.ID
+			}
+			add(user.ID, models.MentionUser)
+		}
+	}
+
+	mentions := make([]models.Mention, 0, len(kinds))
+	for userID, kind := range kinds {
+		mentions = append(mentions, models.Mention{
+			MessageID: message.ID,
+			RoomID:    message.RoomID,
+			UserID:    userID,
+			Kind:      kind,
+		})
+	}
+	return mentions, userIDs, nil
+}
+
+// Priority of mention kinds when a user is mentioned more than once
+var mentionRank = map[string]int{
+	models.MentionHere: 1,
+	models.MentionRoom: 2,
+	models.MentionUser: 3,
+}
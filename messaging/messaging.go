@@ -0,0 +1,113 @@
+package messaging
+
+import (
+	"github.com/CUknot/network_backend/database"
+	"github.com/CUknot/network_backend/markdown"
+	"github.com/CUknot/network_backend/models"
+	"github.com/CUknot/network_backend/notifications"
+	"github.com/CUknot/network_backend/unfurl"
+	"github.com/CUknot/network_backend/webhooks"
+	"github.com/CUknot/network_backend/websocket"
+	"gorm.io/gorm"
+)
+
+// MentionEvent is the payload pushed to a user when they are mentioned
+type MentionEvent struct {
+	Mention models.Mention `json:"mention"`
+	Message models.Message `json:"message"`
+}
+
+// PollOptionOrder preloads a poll's options in the order they were given
+func PollOptionOrder(db *gorm.DB) *gorm.DB {
+	return db.Order("position")
+}
+
+// Post saves a message and publishes it to its room
+func Post(message *models.Message) error {
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		return Save(tx, message)
+	}); err != nil {
+		return err
+	}
+
+	Publish(message)
+	return nil
+}
+
+// Save inserts a message together with its parsed body and the mentions it contains
+func Save(tx *gorm.DB, message *models.Message) error {
+	mentions, err := parse(tx, message)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Create(message).Error; err != nil {
+		return err
+	}
+
+	for i := range mentions {
+		mentions[i].MessageID = message.ID
+	}
+	if len(mentions) > 0 {
+		if err := tx.Create(&mentions).Error; err != nil {
+			return err
+		}
+	}
+	message.Mentions = mentions
+
+	return nil
+}
+
+// parse renders a message's content into its body and resolves the mentions in it
+func parse(tx *gorm.DB, message *models.Message) ([]models.Mention, error) {
+	body := markdown.Parse(message.Content)
+	message.Body = &body
+
+	// A forwarded copy shows the original's mentions without pinging anyone again
+	if message.ForwardedFromID != nil {
+		return nil, nil
+	}
+
+	mentions, userIDs, err := resolveMentions(tx, message)
+	if err != nil {
+		return nil, err
+	}
+	message.Body.ResolveMentions(userIDs)
+	return mentions, nil
+}
+
+// Publish broadcasts a saved message to its room and notifies the users it mentions
+func Publish(message *models.Message) {
+	// Load user data for the message
+	database.DB.Preload("User").Preload("Mentions").Preload("Poll.Options", PollOptionOrder).First(message, message.ID)
+
+	// Broadcast message to room
+	Broadcast("message", message)
+
+	for _, mention := range message.Mentions {
+		// Muted rooms still record the mention but raise no badge
+		if !notifications.Allowed(mention.UserID, message.RoomID, true) {
+			continue
+		}
+		event := MentionEvent{Mention: mention, Message: *message}
+		if err := HideQuotes([]*models.Message{&event.Message}, mention.UserID); err != nil {
+			event.Message.Quote = nil
+		}
+		websocket.SendToUser(mention.UserID, "mention", event)
+	}
+
+	webhooks.Dispatch(message.RoomID, models.EventMessageCreated, webhooks.MessageData(*message))
+
+	// Reach offline users through push notifications
+	go notifications.MessagePosted(*message)
+
+	// Fetch link previews in the background
+	go unfurlMessage(*message)
+}
+
+// unfurlMessage adds link previews to a message and tells its room
+func unfurlMessage(message models.Message) {
+	if updated, ok := unfurl.Message(message); ok {
+		Broadcast("message_updated", updated)
+	}
+}
@@ -0,0 +1,115 @@
+package messaging
+
+import (
+	"log"
+
+	"github.com/CUknot/network_backend/database"
+	"github.com/CUknot/network_backend/models"
+	"github.com/CUknot/network_backend/websocket"
+)
+
+// NewQuote takes a snapshot of a message for quoting it in another message
+func NewQuote(quoted models.Message) *models.Quote {
+	return &models.Quote{
+		MessageID:  quoted.ID,
+		RoomID:     quoted.RoomID,
+		UserID:     quoted.UserID,
+		SenderName: quoted.Sender(),
+		Content:    quoted.Content,
+		CreatedAt:  quoted.CreatedAt,
+	}
+}
+
+// Broadcast sends a message event to the message's room. A quote from another
+// room is only sent to members who can also see that room.
+func Broadcast(event string, message *models.Message) {
+	if !crossRoomQuote(message) {
+		websocket.BroadcastToRoomFrom(message.RoomID, message.UserID, event, message)
+		return
+	}
+
+	readers, err := quoteReaders(message)
+	if err != nil {
+		log.Printf("error loading readers of the quote in message %d: %v", message.ID, err)
+	}
+	websocket.BroadcastToRoomWhere(message.RoomID, message.UserID, func(userID uint) bool {
+		return readers[userID]
+	}, event, message)
+
+	hidden := withoutQuote(*message)
+	websocket.BroadcastToRoomWhere(message.RoomID, message.UserID, func(userID uint) bool {
+		return !readers[userID]
+	}, event, &hidden)
+}
+
+// HideQuotes removes quotes of messages the viewer can't see from messages
+func HideQuotes(messages []*models.Message, viewerID uint) error {
+	roomIDs := make(map[uint]bool)
+	for _, message := range messages {
+		if crossRoomQuote(message) {
+			roomIDs[message.Quote.RoomID] = true
+		}
+	}
+	if len(roomIDs) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, 0, len(roomIDs))
+	for id := range roomIDs {
+		ids = append(ids, id)
+	}
+	var memberOf []uint
+	if err := database.DB.Model(&models.RoomUser{}).
+		Where("user_id = ? AND room_id IN ?", viewerID, ids).
+		Pluck("room_id", &memberOf).Error; err != nil {
+		return err
+	}
+	visible := make(map[uint]bool, len(memberOf))
+	for _, id := range memberOf {
+		visible[id] = true
+	}
+
+	for _, message := range messages {
+		if crossRoomQuote(message) && !visible[message.Quote.RoomID] {
+			message.Quote = nil
+		}
+	}
+	return nil
+}
+
+// DropForeignQuote removes a quote from another room from a message that is
+// shared with everyone in its room
+func DropForeignQuote(message *models.Message) {
+	if crossRoomQuote(message) {
+		message.Quote = nil
+	}
+}
+
+// crossRoomQuote reports whether a message quotes a message from another room
+func crossRoomQuote(message *models.Message) bool {
+	return message != nil && message.Quote != nil && message.Quote.RoomID != message.RoomID
+}
+
+// quoteReaders returns the members of a message's room who also belong to the
+// room of the message it quotes
+func quoteReaders(message *models.Message) (map[uint]bool, error) {
+	var userIDs []uint
+	if err := database.DB.Model(&models.RoomUser{}).
+		Where("room_id = ? AND user_id IN (?)", message.Quote.RoomID,
+			database.DB.Model(&models.RoomUser{}).Select("user_id").Where("room_id = ?", message.RoomID)).
+		Pluck("user_id", &userIDs).Error; err != nil {
+		return nil, err
+	}
+
+	readers := make(map[uint]bool, len(userIDs))
+	for _, id := range userIDs {
+		readers[id] = true
+	}
+	return readers, nil
+}
+
+// withoutQuote returns a copy of a message with its quote left out
+func withoutQuote(message models.Message) models.Message {
+	message.Quote = nil
+	return message
+}
@@ -2,11 +2,10 @@ package middleware
 
 import (
 	"net/http"
-	"os"
 	"strings"
 
+	"github.com/CUknot/network_backend/utils"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v4"
 )
 
 // JWTAuth middleware for JWT authentication
@@ -26,22 +25,16 @@ func JWTAuth() gin.HandlerFunc {
 			return
 		}
 
-		tokenString := parts[1]
-		claims := jwt.MapClaims{}
-
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return []byte(os.Getenv("JWT_SECRET")), nil
-		})
-
-		if err != nil || !token.Valid {
+		session, err := utils.AuthenticateToken(parts[1])
+		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
 			c.Abort()
 			return
 		}
 
-		// Extract user ID from token
-		userID := uint(claims["user_id"].(float64))
-		c.Set("userID", userID)
+		// Expose the authenticated user and session to handlers
+		c.Set("userID", session.UserID)
+		c.Set("sessionID", session.ID)
 		c.Next()
 	}
 }
@@ -0,0 +1,14 @@
+package models
+
+import (
+	"time"
+)
+
+type AuditLog struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Action    string    `gorm:"size:64;not null;index" json:"action"`
+	UserID    *uint     `gorm:"index" json:"user_id,omitempty"`
+	IPAddress string    `gorm:"size:45" json:"ip_address,omitempty"`
+	Details   string    `gorm:"type:text" json:"details,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+)
+
+type Bookmark struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_user_bookmark,priority:1" json:"user_id"`
+	MessageID uint      `gorm:"not null;uniqueIndex:idx_user_bookmark,priority:2;index" json:"message_id"`
+	Message   *Message  `gorm:"foreignKey:MessageID" json:"message,omitempty"`
+	RoomID    uint      `gorm:"not null;index" json:"room_id"`
+	Note      string    `gorm:"size:500;not null;default:''" json:"note"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
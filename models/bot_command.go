@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+)
+
+type BotCommand struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	RoomID      uint      `gorm:"not null;uniqueIndex:idx_room_command" json:"room_id"`
+	Name        string    `gorm:"size:32;not null;uniqueIndex:idx_room_command" json:"name"`
+	Description string    `gorm:"size:255" json:"description"`
+	URL         string    `gorm:"type:text;not null" json:"url"`
+	Secret      string    `gorm:"size:64;not null" json:"-"`
+	UserID      uint      `gorm:"not null" json:"user_id"`
+	User        User      `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	CreatedBy   uint      `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
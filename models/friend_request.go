@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+)
+
+// Friend request states
+const (
+	FriendRequestPending  = "pending"
+	FriendRequestAccepted = "accepted"
+)
+
+type FriendRequest struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	SenderID   uint      `gorm:"not null;index" json:"sender_id"`
+	Sender     User      `gorm:"foreignKey:SenderID" json:"sender,omitempty"`
+	ReceiverID uint      `gorm:"not null;index" json:"receiver_id"`
+	Receiver   User      `gorm:"foreignKey:ReceiverID" json:"receiver,omitempty"`
+	Status     string    `gorm:"size:16;not null;default:pending;index" json:"status"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+)
+
+type IncomingWebhook struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	RoomID     uint       `gorm:"not null;index" json:"room_id"`
+	Name       string     `gorm:"size:255;not null" json:"name"`
+	TokenHash  string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	UserID     uint       `gorm:"not null" json:"user_id"`
+	User       User       `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	CreatedBy  uint       `json:"created_by"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
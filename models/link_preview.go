@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+)
+
+// LinkPreview is the metadata shown for a link in a message
+type LinkPreview struct {
+	URL         string `json:"url"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	SiteName    string `json:"site_name,omitempty"`
+	ImageURL    string `json:"image_url,omitempty"`
+}
+
+type LinkPreviewCache struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	URL         string    `gorm:"size:2048;not null;uniqueIndex" json:"url"`
+	Title       string    `gorm:"size:300" json:"title"`
+	Description string    `gorm:"size:1000" json:"description"`
+	SiteName    string    `gorm:"size:300" json:"site_name"`
+	ImageURL    string    `gorm:"size:2048" json:"image_url"`
+	Failed      bool      `gorm:"not null;default:false" json:"failed"`
+	FetchedAt   time.Time `gorm:"not null" json:"fetched_at"`
+}
+
+// Preview returns the cached preview
+func (c *LinkPreviewCache) Preview() LinkPreview {
+	return LinkPreview{
+		URL:         c.URL,
+		Title:       c.Title,
+		Description: c.Description,
+		SiteName:    c.SiteName,
+		ImageURL:    c.ImageURL,
+	}
+}
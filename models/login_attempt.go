@@ -0,0 +1,11 @@
+package models
+
+import (
+	"time"
+)
+
+type LoginAttempt struct {
+	Key           string    `gorm:"primaryKey;size:320" json:"key"`
+	Failures      int       `gorm:"not null;default:0" json:"failures"`
+	LastFailureAt time.Time `json:"last_failure_at"`
+}
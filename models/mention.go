@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+)
+
+// Kinds of mention
+const (
+	MentionUser = "user"
+	MentionHere = "here"
+	MentionRoom = "room"
+)
+
+type Mention struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	MessageID uint       `gorm:"not null;index" json:"message_id"`
+	Message   *Message   `gorm:"foreignKey:MessageID" json:"message,omitempty"`
+	RoomID    uint       `gorm:"not null;index" json:"room_id"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	Kind      string     `gorm:"size:8;not null" json:"kind"`
+	ReadAt    *time.Time `json:"read_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
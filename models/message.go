@@ -2,14 +2,79 @@ package models
 
 import (
 	"time"
+
+	"github.com/CUknot/network_backend/markdown"
+)
+
+// Message types
+const (
+	MessageText   = "text"
+	MessageAction = "action"
+	MessagePoll   = "poll"
 )
 
 type Message struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	Content   string    `gorm:"type:text;not null" json:"content"`
-	RoomID    uint      `json:"room_id"`
-	UserID    uint      `json:"user_id"`
-	User      User      `gorm:"foreignKey:UserID" json:"user,omitempty"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID              uint               `gorm:"primaryKey" json:"id"`
+	Type            string             `gorm:"size:16;not null;default:text" json:"type"`
+	Content         string             `gorm:"type:text;not null" json:"content"`
+	Body            *markdown.Document `gorm:"type:jsonb" json:"body,omitempty"`
+	RoomID          uint               `gorm:"uniqueIndex:idx_client_msg,priority:2" json:"room_id"`
+	UserID          uint               `gorm:"uniqueIndex:idx_client_msg,priority:1" json:"user_id"`
+	ClientMsgID     *string            `gorm:"size:64;uniqueIndex:idx_client_msg,priority:3" json:"client_msg_id,omitempty"`
+	User            User               `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Mentions        []Mention          `gorm:"foreignKey:MessageID" json:"mentions,omitempty"`
+	SenderName      string             `gorm:"size:255" json:"sender_name,omitempty"`
+	Attachments     []Attachment       `gorm:"type:jsonb;serializer:json" json:"attachments,omitempty"`
+	Previews        []LinkPreview      `gorm:"type:jsonb;serializer:json" json:"previews,omitempty"`
+	Poll            *Poll              `gorm:"foreignKey:MessageID" json:"poll,omitempty"`
+	ForwardedFromID *uint              `gorm:"index" json:"forwarded_from_id,omitempty"`
+	QuotedMessageID *uint              `gorm:"index" json:"quoted_message_id,omitempty"`
+	Quote           *Quote             `gorm:"type:jsonb;serializer:json" json:"quote,omitempty"`
+	EditedAt        *time.Time         `json:"edited_at"`
+	CreatedAt       time.Time          `json:"created_at"`
+	UpdatedAt       time.Time          `json:"updated_at"`
+}
+
+// Sender returns the name shown for the message's author, preferring a name
+// given by an incoming webhook
+func (m *Message) Sender() string {
+	if m.SenderName != "" {
+		return m.SenderName
+	}
+	return m.User.Handle()
+}
+
+// Attachment follows the shape of Slack's legacy message attachments
+type Attachment struct {
+	Fallback   string            `json:"fallback,omitempty"`
+	Color      string            `json:"color,omitempty"`
+	Pretext    string            `json:"pretext,omitempty"`
+	AuthorName string            `json:"author_name,omitempty"`
+	AuthorLink string            `json:"author_link,omitempty"`
+	AuthorIcon string            `json:"author_icon,omitempty"`
+	Title      string            `json:"title,omitempty"`
+	TitleLink  string            `json:"title_link,omitempty"`
+	Text       string            `json:"text,omitempty"`
+	Fields     []AttachmentField `json:"fields,omitempty"`
+	ImageURL   string            `json:"image_url,omitempty"`
+	ThumbURL   string            `json:"thumb_url,omitempty"`
+	Footer     string            `json:"footer,omitempty"`
+	FooterIcon string            `json:"footer_icon,omitempty"`
+	Ts         int64             `json:"ts,omitempty"`
+}
+
+type AttachmentField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+// Quote is a snapshot of a quoted message taken when the quote was posted
+type Quote struct {
+	MessageID  uint      `json:"message_id"`
+	RoomID     uint      `json:"room_id"`
+	UserID     uint      `json:"user_id"`
+	SenderName string    `json:"sender_name"`
+	Content    string    `json:"content"`
+	CreatedAt  time.Time `json:"created_at"`
 }
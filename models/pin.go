@@ -0,0 +1,15 @@
+package models
+
+import (
+	"time"
+)
+
+type Pin struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	RoomID       uint      `gorm:"not null;uniqueIndex:idx_room_pin,priority:1" json:"room_id"`
+	MessageID    uint      `gorm:"not null;uniqueIndex:idx_room_pin,priority:2" json:"message_id"`
+	Message      *Message  `gorm:"foreignKey:MessageID" json:"message,omitempty"`
+	PinnedBy     uint      `gorm:"not null" json:"pinned_by"`
+	PinnedByUser User      `gorm:"foreignKey:PinnedBy" json:"pinned_by_user"`
+	CreatedAt    time.Time `json:"created_at"`
+}
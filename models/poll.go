@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+)
+
+type Poll struct {
+	ID             uint         `gorm:"primaryKey" json:"id"`
+	MessageID      uint         `gorm:"not null;uniqueIndex" json:"message_id"`
+	RoomID         uint         `gorm:"not null;index" json:"room_id"`
+	MultipleChoice bool         `gorm:"not null;default:false" json:"multiple_choice"`
+	Anonymous      bool         `gorm:"not null;default:false" json:"anonymous"`
+	ClosesAt       *time.Time   `json:"closes_at"`
+	CreatedBy      uint         `gorm:"not null" json:"created_by"`
+	Options        []PollOption `gorm:"foreignKey:PollID" json:"options"`
+	TotalVoters    int64        `gorm:"-" json:"total_voters"`
+	MyVotes        []uint       `gorm:"-" json:"my_votes,omitempty"`
+	CreatedAt      time.Time    `json:"created_at"`
+}
+
+type PollOption struct {
+	ID       uint   `gorm:"primaryKey" json:"id"`
+	PollID   uint   `gorm:"not null;index" json:"poll_id"`
+	Position int    `gorm:"not null" json:"position"`
+	Text     string `gorm:"size:200;not null" json:"text"`
+	Votes    int64  `gorm:"-" json:"votes"`
+	Voters   []uint `gorm:"-" json:"voters,omitempty"`
+}
+
+type PollVote struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	PollID    uint      `gorm:"not null;uniqueIndex:idx_poll_vote,priority:1" json:"poll_id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_poll_vote,priority:2" json:"user_id"`
+	OptionID  uint      `gorm:"not null;uniqueIndex:idx_poll_vote,priority:3;index" json:"option_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Closed reports whether a poll no longer accepts votes
+func (p *Poll) Closed() bool {
+	return p.ClosesAt != nil && !p.ClosesAt.After(time.Now())
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+)
+
+type PushSubscription struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;index" json:"user_id"`
+	Endpoint  string    `gorm:"type:text;not null;uniqueIndex" json:"endpoint"`
+	P256dh    string    `gorm:"size:255;not null" json:"-"`
+	Auth      string    `gorm:"size:255;not null" json:"-"`
+	UserAgent string    `gorm:"size:512" json:"user_agent"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type VapidKey struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	PublicKey  string    `gorm:"size:255;not null" json:"public_key"`
+	PrivateKey string    `gorm:"size:255;not null" json:"-"`
+	CreatedAt  time.Time `json:"created_at"`
+}
@@ -5,17 +5,46 @@ import (
 )
 
 type Room struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	Name      string    `gorm:"size:255;not null" json:"name"`
-	CreatedBy uint      `json:"created_by"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Users     []User    `gorm:"many2many:room_users;" json:"users,omitempty"`
-	Messages  []Message `json:"messages,omitempty"`
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	Name       string    `gorm:"size:255;not null" json:"name"`
+	Topic      string    `gorm:"size:512;not null;default:''" json:"topic"`
+	CreatedBy  uint      `json:"created_by"`
+	IsDirect   bool      `gorm:"not null;default:false" json:"is_direct"`
+	MessageTTL int64     `gorm:"not null;default:0" json:"message_ttl"` // seconds, 0 keeps messages forever
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	Users      []User    `gorm:"many2many:room_users;" json:"users,omitempty"`
+	Messages   []Message `json:"messages,omitempty"`
+
+	// The requesting user's notification settings for the room
+	Notifications *RoomNotificationSettings `gorm:"-" json:"notifications,omitempty"`
+}
+
+type RoomNotificationSettings struct {
+	Level      string     `json:"level"`
+	MutedUntil *time.Time `json:"muted_until"`
 }
 
+// Member roles within a room
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+)
+
 type RoomUser struct {
-	RoomID    uint      `gorm:"primaryKey" json:"room_id"`
-	UserID    uint      `gorm:"primaryKey" json:"user_id"`
-	CreatedAt time.Time `json:"created_at"`
+	RoomID     uint       `gorm:"primaryKey" json:"room_id"`
+	UserID     uint       `gorm:"primaryKey" json:"user_id"`
+	Role       string     `gorm:"size:16;not null;default:member" json:"role"`
+	LastReadAt *time.Time `json:"last_read_at"`
+
+	// Empty NotificationLevel falls back to the user's default
+	NotificationLevel string     `gorm:"size:16;not null;default:''" json:"notification_level"`
+	MutedUntil        *time.Time `json:"muted_until"`
+	CreatedAt         time.Time  `json:"created_at"`
+}
+
+// Muted reports whether notifications for the membership are muted at the given time
+func (ru *RoomUser) Muted(now time.Time) bool {
+	return ru.MutedUntil != nil && ru.MutedUntil.After(now)
 }
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+)
+
+// Scheduled message states
+const (
+	ScheduledPending   = "pending"
+	ScheduledSent      = "sent"
+	ScheduledCancelled = "cancelled"
+	ScheduledFailed    = "failed"
+)
+
+type ScheduledMessage struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	RoomID      uint       `gorm:"not null;index;uniqueIndex:idx_scheduled_client_msg,priority:2" json:"room_id"`
+	UserID      uint       `gorm:"not null;index;uniqueIndex:idx_scheduled_client_msg,priority:1" json:"user_id"`
+	ClientMsgID *string    `gorm:"size:64;uniqueIndex:idx_scheduled_client_msg,priority:3" json:"client_msg_id,omitempty"`
+	Content     string     `gorm:"type:text;not null" json:"content"`
+	SendAt      time.Time  `gorm:"not null;index:idx_scheduled_queue,priority:2" json:"send_at"`
+	Status      string     `gorm:"size:16;not null;default:pending;index:idx_scheduled_queue,priority:1" json:"status"`
+	MessageID   *uint      `json:"message_id"`
+	SentAt      *time.Time `json:"sent_at"`
+	LastError   string     `gorm:"type:text" json:"last_error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
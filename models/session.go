@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+)
+
+type Session struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	UserID     uint      `gorm:"not null;index" json:"user_id"`
+	DeviceName string    `gorm:"size:255" json:"device_name"`
+	UserAgent  string    `gorm:"size:512" json:"user_agent"`
+	IPAddress  string    `gorm:"size:45" json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	Current    bool      `gorm:"-" json:"current"`
+}
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"fmt"
+	"strings"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
@@ -8,29 +10,55 @@ import (
 )
 
 type User struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	Username  string    `gorm:"size:255;not null;index:idx_username_tag,unique" json:"username"`
-	Tag       string    `gorm:"size:4;not null;index:idx_username_tag,unique" json:"tag"`
-	Email     string    `gorm:"size:255;not null;unique" json:"email"`
-	Password  string    `gorm:"size:255;not null" json:"-"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Rooms     []Room    `gorm:"many2many:room_users;" json:"-"`
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	Username     string     `gorm:"size:255;not null;index:idx_username_tag,unique" json:"username"`
+	Tag          string     `gorm:"size:4;not null;index:idx_username_tag,unique" json:"tag"`
+	Email        string     `gorm:"size:255;not null;unique" json:"email"`
+	Password     string     `gorm:"size:255;not null" json:"-"`
+	TagChangedAt *time.Time `json:"tag_changed_at,omitempty"`
+	IsBot        bool       `gorm:"not null;default:false" json:"is_bot"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	DeletedAt    *time.Time `json:"deleted_at,omitempty"`
+	Rooms        []Room     `gorm:"many2many:room_users;" json:"-"`
 }
 
 // BeforeSave hashes the password before saving to the database
 func (u *User) BeforeSave(tx *gorm.DB) error {
 	if u.Password != "" {
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(u.Password), bcrypt.DefaultCost)
+		hashedPassword, err := HashPassword(u.Password)
 		if err != nil {
 			return err
 		}
-		u.Password = string(hashedPassword)
+		u.Password = hashedPassword
 	}
 	return nil
 }
 
+// HashPassword returns the bcrypt hash stored for a plain-text password
+func HashPassword(password string) (string, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashedPassword), nil
+}
+
 // ValidatePassword checks if the provided password matches the stored hash
 func (u *User) ValidatePassword(password string) error {
 	return bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
 }
+
+// Handle returns the user's unique username#tag handle
+func (u *User) Handle() string {
+	return u.Username + "#" + u.Tag
+}
+
+// ParseHandle splits a username#tag handle into its username and tag
+func ParseHandle(handle string) (string, string, error) {
+	i := strings.LastIndex(handle, "#")
+	if i <= 0 || len(handle)-i-1 != 4 {
+		return "", "", fmt.Errorf("invalid handle %q, expected username#tag", handle)
+	}
+	return handle[:i], handle[i+1:], nil
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+)
+
+// Email digest frequencies
+const (
+	DigestOff    = "off"
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// Notification levels shared by user defaults and per-room settings
+const (
+	NotifyAll      = "all"
+	NotifyMentions = "mentions"
+	NotifyNone     = "none"
+)
+
+type UserPreferences struct {
+	UserID            uint       `gorm:"primaryKey" json:"user_id"`
+	Theme             string     `gorm:"size:16;not null;default:system" json:"theme"`
+	NotificationLevel string     `gorm:"size:16;not null;default:all" json:"notification_level"`
+	NotificationSound bool       `gorm:"not null;default:true" json:"notification_sound"`
+	FriendsOnly       bool       `gorm:"not null;default:false" json:"friends_only"`
+	DigestFrequency   string     `gorm:"size:16;not null;default:daily" json:"digest_frequency"`
+	LastDigestAt      *time.Time `json:"last_digest_at,omitempty"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+// DefaultPreferences returns the preferences used for a user who has never saved any
+func DefaultPreferences(userID uint) UserPreferences {
+	return UserPreferences{
+		UserID:            userID,
+		Theme:             "system",
+		NotificationLevel: NotifyAll,
+		NotificationSound: true,
+		DigestFrequency:   DigestDaily,
+	}
+}
@@ -0,0 +1,141 @@
+package notifications
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+	"unicode/utf8"
+
+	"github.com/CUknot/network_backend/database"
+	"github.com/CUknot/network_backend/models"
+	"github.com/CUknot/network_backend/push"
+	"github.com/CUknot/network_backend/websocket"
+)
+
+// Longest message excerpt included in a notification
+const maxBodyLength = 200
+
+// Payload is the JSON delivered to the service worker in a push message
+type Payload struct {
+	Type      string `json:"type"`
+	RoomID    uint   `json:"room_id"`
+	MessageID uint   `json:"message_id"`
+	Title     string `json:"title"`
+	Body      string `json:"body"`
+}
+
+// MessagePosted sends push notifications for a new message to offline users
+// who were messaged directly or mentioned in it
+func MessagePosted(message models.Message) {
+	var room models.Room
+	if err := database.DB.First(&room, message.RoomID).Error; err != nil {
+		log.Printf("error loading room %d for notifications: %v", message.RoomID, err)
+		return
+	}
+
+	recipients := make(map[uint]string)
+
+	// Every other participant of a direct message hears about it, unless they blocked the sender
+	if room.IsDirect {
+		var memberIDs []uint
+		database.DB.Model(&models.RoomUser{}).
+			Where("room_id = ? AND user_id != ?", room.ID, message.UserID).
+			Where("user_id NOT IN (?)", database.DB.Model(&models.Block{}).Select("blocker_id").Where("blocked_id = ?", message.UserID)).
+			Pluck("user_id", &memberIDs)
+		for _, id := range memberIDs {
+			recipients[id] = "message"
+		}
+	}
+
+	for _, mention := range message.Mentions {
+		recipients[mention.UserID] = "mention"
+	}
+
+	for userID, kind := range recipients {
+		// Users with a live connection already received the message
+		if websocket.IsOnline(userID) {
+			continue
+		}
+		if !Allowed(userID, room.ID, kind == "mention") {
+			continue
+		}
+
+		payload := Payload{
+			Type:      kind,
+			RoomID:    room.ID,
+			MessageID: message.ID,
+			Title:     title(message, room, kind),
+			Body:      excerpt(message.Content),
+		}
+		sendToUser(userID, payload)
+	}
+}
+
+// Allowed reports whether a user wants to be notified about a message in a room,
+// applying the room's mute and level before the user's default level
+func Allowed(userID, roomID uint, mentioned bool) bool {
+	var roomUser models.RoomUser
+	if err := database.DB.Where("room_id = ? AND user_id = ?", roomID, userID).First(&roomUser).Error; err != nil {
+		return false
+	}
+	if roomUser.Muted(time.Now()) {
+		return false
+	}
+
+	level := roomUser.NotificationLevel
+	if level == "" {
+		preferences := models.DefaultPreferences(userID)
+		if err := database.DB.Where("user_id = ?", userID).Limit(1).Find(&preferences).Error; err != nil {
+			return false
+		}
+		level = preferences.NotificationLevel
+	}
+
+	switch level {
+	case models.NotifyNone:
+		return false
+	case models.NotifyMentions:
+		return mentioned
+	default:
+		return true
+	}
+}
+
+// sendToUser pushes a payload to every subscription of a user
+func sendToUser(userID uint, payload Payload) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("error marshaling push payload: %v", err)
+		return
+	}
+
+	var subscriptions []models.PushSubscription
+	if err := database.DB.Where("user_id = ?", userID).Find(&subscriptions).Error; err != nil {
+		log.Printf("error loading push subscriptions for user %d: %v", userID, err)
+		return
+	}
+
+	for _, subscription := range subscriptions {
+		if err := push.Send(subscription, data); err != nil {
+			log.Printf("error sending push notification to user %d: %v", userID, err)
+		}
+	}
+}
+
+// title returns the notification title for a message
+func title(message models.Message, room models.Room, kind string) string {
+	sender := message.Sender()
+	if kind == "mention" && !room.IsDirect {
+		return fmt.Sprintf("%s mentioned you in %s", sender, room.Name)
+	}
+	return sender
+}
+
+// excerpt shortens message content to fit in a notification
+func excerpt(content string) string {
+	if utf8.RuneCountInString(content) <= maxBodyLength {
+		return content
+	}
+	return string([]rune(content)[:maxBodyLength]) + "…"
+}
@@ -0,0 +1,86 @@
+package push
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+)
+
+// Record size advertised in the aes128gcm header
+const recordSize = 4096
+
+// MaxPayloadSize is the largest plaintext that fits in a single record
+const MaxPayloadSize = recordSize - 16 - 1
+
+// encrypt encrypts a payload for a subscription as described in RFC 8291,
+// using the aes128gcm content coding from RFC 8188 with a single record
+func encrypt(plaintext, userAgentPublic, authSecret []byte) ([]byte, error) {
+	if len(plaintext) > MaxPayloadSize {
+		return nil, errors.New("push payload too large")
+	}
+
+	curve := ecdh.P256()
+	uaPublic, err := curve.NewPublicKey(userAgentPublic)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fresh application server key pair for every message
+	asPrivate, err := curve.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	asPublic := asPrivate.PublicKey().Bytes()
+
+	sharedSecret, err := asPrivate.ECDH(uaPublic)
+	if err != nil {
+		return nil, err
+	}
+
+	// Combine the shared secret with the subscription's auth secret
+	keyInfo := "WebPush: info\x00" + string(userAgentPublic) + string(asPublic)
+	ikm, err := hkdf.Key(sha256.New, sharedSecret, authSecret, keyInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	cek, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// A 0x02 delimiter marks the last (and only) record
+	record := append(append([]byte{}, plaintext...), 0x02)
+
+	// Header: salt || record size || key ID length || key ID (the server public key)
+	header := make([]byte, 0, 16+4+1+len(asPublic))
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, recordSize)
+	header = append(header, byte(len(asPublic)))
+	header = append(header, asPublic...)
+
+	return gcm.Seal(header, nonce, record, nil), nil
+}
@@ -0,0 +1,105 @@
+package push
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/CUknot/network_backend/database"
+	"github.com/CUknot/network_backend/models"
+	"github.com/CUknot/network_backend/safehttp"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Client is the HTTP client used to reach push services. Endpoints come from
+// browsers, so it refuses private addresses and doesn't follow redirects.
+var Client = safehttp.NewClient(10*time.Second, 0)
+
+// How long a push service should keep an undelivered notification
+const messageTTL = 24 * time.Hour
+
+// Send encrypts a payload and delivers it to a single push subscription.
+// Subscriptions the push service reports as gone are deleted.
+func Send(subscription models.PushSubscription, payload []byte) error {
+	p256dh, err := decodeKey(subscription.P256dh)
+	if err != nil {
+		return fmt.Errorf("invalid p256dh key: %w", err)
+	}
+	auth, err := decodeKey(subscription.Auth)
+	if err != nil {
+		return fmt.Errorf("invalid auth secret: %w", err)
+	}
+
+	body, err := encrypt(payload, p256dh, auth)
+	if err != nil {
+		return err
+	}
+
+	authorization, err := vapidAuthorization(subscription.Endpoint)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, subscription.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", fmt.Sprintf("%d", int(messageTTL.Seconds())))
+	req.Header.Set("Urgency", "high")
+
+	resp, err := Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		// The browser unsubscribed; stop sending to it
+		database.DB.Delete(&models.PushSubscription{}, subscription.ID)
+		return fmt.Errorf("push subscription %d expired", subscription.ID)
+	case resp.StatusCode >= 300:
+		return fmt.Errorf("push service returned %s", resp.Status)
+	}
+	return nil
+}
+
+// vapidAuthorization builds the VAPID Authorization header for a push endpoint (RFC 8292)
+func vapidAuthorization(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+
+	subject := os.Getenv("VAPID_SUBJECT")
+	if subject == "" {
+		subject = "mailto:admin@example.com"
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"aud": u.Scheme + "://" + u.Host,
+		"exp": time.Now().Add(12 * time.Hour).Unix(),
+		"sub": subject,
+	})
+	signed, err := token.SignedString(vapidPrivateKey)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("vapid t=%s, k=%s", signed, vapidPublicKey), nil
+}
+
+// decodeKey decodes a key sent by a browser, which may use either base64 alphabet and padding
+func decodeKey(key string) ([]byte, error) {
+	key = strings.TrimRight(key, "=")
+	key = strings.NewReplacer("+", "-", "/", "_").Replace(key)
+	return base64.RawURLEncoding.DecodeString(key)
+}
@@ -0,0 +1,233 @@
+package push
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/CUknot/network_backend/models"
+	"github.com/CUknot/network_backend/safehttp"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// received is what the stand-in push service saw
+type received struct {
+	header http.Header
+	body   []byte
+}
+
+// testVAPID installs a fresh VAPID key pair the way InitVAPID does from the environment
+func testVAPID(t *testing.T) {
+	t.Helper()
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("VAPID_PUBLIC_KEY", base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes()))
+	t.Setenv("VAPID_PRIVATE_KEY", base64.RawURLEncoding.EncodeToString(key.Bytes()))
+	t.Setenv("VAPID_SUBJECT", "mailto:push-test@example.com")
+	InitVAPID()
+}
+
+// allowPrivate lets the test reach httptest servers on loopback
+func allowPrivate(t *testing.T) {
+	t.Helper()
+	safehttp.AllowPrivate = true
+	t.Cleanup(func() {
+		safehttp.AllowPrivate = false
+		Client.CloseIdleConnections()
+	})
+}
+
+func TestSendRoundTrip(t *testing.T) {
+	testVAPID(t)
+	allowPrivate(t)
+
+	// The subscribing browser's keys
+	uaPrivate, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authSecret := make([]byte, 16)
+	rand.Read(authSecret)
+
+	requests := make(chan received, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- received{header: r.Header, body: body}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	t.Cleanup(srv.Close)
+
+	// Browsers may send keys in standard base64 with padding
+	subscription := models.PushSubscription{
+		Endpoint: srv.URL + "/push/abc123",
+		P256dh:   base64.StdEncoding.EncodeToString(uaPrivate.PublicKey().Bytes()),
+		Auth:     base64.URLEncoding.EncodeToString(authSecret),
+	}
+	payload := []byte(`{"title":"alice#1234","body":"hello there"}`)
+	if err := Send(subscription, payload); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	req := <-requests
+
+	if got := req.header.Get("Content-Encoding"); got != "aes128gcm" {
+		t.Errorf("Content-Encoding = %q", got)
+	}
+	if req.header.Get("TTL") == "" {
+		t.Error("TTL header missing")
+	}
+
+	plaintext, err := decrypt(req.body, uaPrivate, authSecret)
+	if err != nil {
+		t.Fatalf("decrypting push body: %v", err)
+	}
+	if !bytes.Equal(plaintext, payload) {
+		t.Errorf("decrypted payload = %s, want %s", plaintext, payload)
+	}
+
+	verifyVAPID(t, req.header.Get("Authorization"), srv.URL)
+}
+
+func TestSendRefusesLoopback(t *testing.T) {
+	testVAPID(t)
+	safehttp.AllowPrivate = false
+	Client.CloseIdleConnections()
+
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { hits.Add(1) }))
+	t.Cleanup(srv.Close)
+
+	uaPrivate, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subscription := models.PushSubscription{
+		Endpoint: srv.URL + "/push/abc123",
+		P256dh:   base64.RawURLEncoding.EncodeToString(uaPrivate.PublicKey().Bytes()),
+		Auth:     base64.RawURLEncoding.EncodeToString(make([]byte, 16)),
+	}
+	if err := Send(subscription, []byte("{}")); !errors.Is(err, safehttp.ErrPrivateAddress) {
+		t.Errorf("Send error = %v, want %v", err, safehttp.ErrPrivateAddress)
+	}
+	if hits.Load() != 0 {
+		t.Error("loopback push service was reached")
+	}
+}
+
+func TestEncryptRejectsLargePayload(t *testing.T) {
+	uaPrivate, _ := ecdh.P256().GenerateKey(rand.Reader)
+	if _, err := encrypt(make([]byte, MaxPayloadSize+1), uaPrivate.PublicKey().Bytes(), make([]byte, 16)); err == nil {
+		t.Error("encrypt accepted a payload larger than one record")
+	}
+}
+
+// decrypt reverses encrypt as a browser would (RFC 8291 and RFC 8188)
+func decrypt(body []byte, uaPrivate *ecdh.PrivateKey, authSecret []byte) ([]byte, error) {
+	salt := body[:16]
+	if size := binary.BigEndian.Uint32(body[16:20]); size != recordSize {
+		return nil, io.ErrUnexpectedEOF
+	}
+	idLen := int(body[20])
+	asPublicBytes := body[21 : 21+idLen]
+	ciphertext := body[21+idLen:]
+
+	asPublic, err := ecdh.P256().NewPublicKey(asPublicBytes)
+	if err != nil {
+		return nil, err
+	}
+	sharedSecret, err := uaPrivate.ECDH(asPublic)
+	if err != nil {
+		return nil, err
+	}
+
+	keyInfo := "WebPush: info\x00" + string(uaPrivate.PublicKey().Bytes()) + string(asPublicBytes)
+	ikm, err := hkdf.Key(sha256.New, sharedSecret, authSecret, keyInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+	cek, _ := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: aes128gcm\x00", 16)
+	nonce, _ := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: nonce\x00", 12)
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	record, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// The last record ends with a 0x02 delimiter
+	if len(record) == 0 || record[len(record)-1] != 0x02 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return record[:len(record)-1], nil
+}
+
+// verifyVAPID checks a VAPID Authorization header as a push service would (RFC 8292)
+func verifyVAPID(t *testing.T, header, origin string) {
+	t.Helper()
+
+	if !strings.HasPrefix(header, "vapid ") {
+		t.Fatalf("Authorization = %q, want a vapid scheme", header)
+	}
+	params := make(map[string]string)
+	for _, part := range strings.Split(strings.TrimPrefix(header, "vapid "), ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		params[key] = value
+	}
+	if params["k"] != PublicKey() {
+		t.Errorf("k = %q, want the VAPID public key", params["k"])
+	}
+
+	point, err := base64.RawURLEncoding.DecodeString(params["k"])
+	if err != nil || len(point) != 65 {
+		t.Fatalf("invalid k parameter %q", params["k"])
+	}
+	publicKey := &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(point[1:33]),
+		Y:     new(big.Int).SetBytes(point[33:]),
+	}
+
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(params["t"], claims, func(token *jwt.Token) (interface{}, error) {
+		return publicKey, nil
+	}, jwt.WithValidMethods([]string{"ES256"}))
+	if err != nil || !token.Valid {
+		t.Fatalf("VAPID JWT doesn't verify: %v", err)
+	}
+
+	if claims["aud"] != origin {
+		t.Errorf("aud = %v, want %s", claims["aud"], origin)
+	}
+	if claims["sub"] != "mailto:push-test@example.com" {
+		t.Errorf("sub = %v", claims["sub"])
+	}
+	exp, _ := claims["exp"].(float64)
+	if until := time.Until(time.Unix(int64(exp), 0)); until <= 0 || until > 24*time.Hour {
+		t.Errorf("exp is %v from now, want within 24 hours", until)
+	}
+}
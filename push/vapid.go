@@ -0,0 +1,102 @@
+package push
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"log"
+	"math/big"
+	"os"
+
+	"github.com/CUknot/network_backend/database"
+	"github.com/CUknot/network_backend/models"
+	"gorm.io/gorm/clause"
+)
+
+// The application server's VAPID key pair
+var (
+	vapidPrivateKey *ecdsa.PrivateKey
+	vapidPublicKey  string
+)
+
+// InitVAPID loads the VAPID key pair from VAPID_PUBLIC_KEY and VAPID_PRIVATE_KEY,
+// falling back to a key pair generated once and shared through the database
+func InitVAPID() {
+	publicKey := os.Getenv("VAPID_PUBLIC_KEY")
+	privateKey := os.Getenv("VAPID_PRIVATE_KEY")
+
+	if publicKey == "" || privateKey == "" {
+		key, err := loadOrCreateKey()
+		if err != nil {
+			log.Fatal("Failed to load VAPID keys:", err)
+		}
+		publicKey, privateKey = key.PublicKey, key.PrivateKey
+	}
+
+	priv, err := parsePrivateKey(privateKey)
+	if err != nil {
+		log.Fatal("Invalid VAPID private key:", err)
+	}
+
+	vapidPrivateKey = priv
+	vapidPublicKey = publicKey
+	log.Println("VAPID keys loaded")
+}
+
+// PublicKey returns the VAPID public key browsers need to subscribe
+func PublicKey() string {
+	return vapidPublicKey
+}
+
+// loadOrCreateKey returns the stored key pair, generating it if no replica has yet
+func loadOrCreateKey() (models.VapidKey, error) {
+	priv, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return models.VapidKey{}, err
+	}
+
+	// Whichever replica inserts first wins; the others read its key
+	candidate := models.VapidKey{
+		ID:         1,
+		PublicKey:  base64.RawURLEncoding.EncodeToString(priv.PublicKey().Bytes()),
+		PrivateKey: base64.RawURLEncoding.EncodeToString(priv.Bytes()),
+	}
+	if err := database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&candidate).Error; err != nil {
+		return models.VapidKey{}, err
+	}
+
+	var key models.VapidKey
+	err = database.DB.First(&key, 1).Error
+	return key, err
+}
+
+// parsePrivateKey decodes a base64url P-256 scalar into a signing key
+func parsePrivateKey(encoded string) (*ecdsa.PrivateKey, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := ecdh.P256().NewPrivateKey(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	// Uncompressed point: 0x04 || X || Y
+	point := key.PublicKey().Bytes()
+	if len(point) != 65 {
+		return nil, errors.New("unexpected public key length")
+	}
+
+	return &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(point[1:33]),
+			Y:     new(big.Int).SetBytes(point[33:]),
+		},
+		D: new(big.Int).SetBytes(raw),
+	}, nil
+}
@@ -0,0 +1,158 @@
+package retention
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CUknot/network_backend/audit"
+	"github.com/CUknot/network_backend/database"
+	"github.com/CUknot/network_backend/messaging"
+	"github.com/CUknot/network_backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// Most messages deleted per transaction
+	batchSize = 500
+
+	// Shortest TTL a room can have
+	MinTTL = time.Minute
+
+	// Longest duration ParseTTL accepts
+	MaxTTL = 3650 * 24 * time.Hour
+)
+
+// Organisation-wide limit on message age, 0 when messages are kept forever
+var globalTTL time.Duration
+
+// Start runs the retention sweeper every RETENTION_INTERVAL (default 1m).
+// MESSAGE_RETENTION (such as 90d) sets an organisation-wide limit that applies
+// on top of each room's own TTL.
+func Start() {
+	interval := time.Minute
+	if value := os.Getenv("RETENTION_INTERVAL"); value != "" {
+		parsed, err := ParseTTL(value)
+		if err != nil {
+			log.Printf("invalid RETENTION_INTERVAL %q, using %s", value, interval)
+		} else {
+			interval = parsed
+		}
+	}
+
+	if value := os.Getenv("MESSAGE_RETENTION"); value != "" {
+		parsed, err := ParseTTL(value)
+		if err != nil || parsed < MinTTL {
+			log.Fatalf("invalid MESSAGE_RETENTION %q", value)
+		}
+		globalTTL = parsed
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			run(time.Now())
+			<-ticker.C
+		}
+	}()
+}
+
+// GlobalTTL returns the organisation-wide retention limit, 0 if there is none
+func GlobalTTL() time.Duration {
+	return globalTTL
+}
+
+// ParseTTL parses a duration such as 30m, 24h, or 7d, up to MaxTTL
+func ParseTTL(value string) (time.Duration, error) {
+	var duration time.Duration
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		// Checked before multiplying so a huge day count can't wrap around
+		n, err := strconv.ParseInt(days, 10, 64)
+		if err != nil || n <= 0 || n > math.MaxInt64/int64(24*time.Hour) {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		duration = time.Duration(n) * 24 * time.Hour
+	} else {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		duration = parsed
+	}
+
+	if duration > MaxTTL {
+		return 0, fmt.Errorf("duration %q is longer than %dd", value, MaxTTL/(24*time.Hour))
+	}
+	return duration, nil
+}
+
+// run deletes every message past its room's TTL or the global limit
+func run(now time.Time) {
+	var rooms []models.Room
+	if err := database.DB.Where("message_ttl > 0").Find(&rooms).Error; err != nil {
+		log.Printf("error loading rooms with a message TTL: %v", err)
+		return
+	}
+	for _, room := range rooms {
+		ttl := time.Duration(room.MessageTTL) * time.Second
+		if globalTTL > 0 && globalTTL < ttl {
+			continue // The global sweep below covers it
+		}
+		expire(room.ID, now.Add(-ttl), fmt.Sprintf("room TTL of %s", ttl))
+	}
+
+	if globalTTL > 0 {
+		expire(0, now.Add(-globalTTL), fmt.Sprintf("retention limit of %s", globalTTL))
+	}
+}
+
+// expire deletes messages created before cutoff, from one room or from every
+// room when roomID is 0, and records how many were removed from each room
+func expire(roomID uint, cutoff time.Time, reason string) {
+	deleted := make(map[uint]int)
+	for {
+		var messages []models.Message
+
+		// Skip rows another replica is already deleting
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			query := tx.Where("created_at < ?", cutoff)
+			if roomID != 0 {
+				query = query.Where("room_id = ?", roomID)
+			}
+			if err := query.
+				Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+				Select("id", "room_id").
+				Limit(batchSize).
+				Find(&messages).Error; err != nil {
+				return err
+			}
+			return messaging.Remove(tx, messages)
+		})
+		if err != nil {
+			log.Printf("error deleting expired messages: %v", err)
+			break
+		}
+
+		messaging.Announce(messages)
+		for _, message := range messages {
+			deleted[message.RoomID]++
+		}
+		if len(messages) < batchSize {
+			break
+		}
+	}
+
+	for roomID, count := range deleted {
+		audit.Record(models.AuditLog{
+			Action:  audit.ActionRetentionDeletion,
+			Details: fmt.Sprintf("deleted %d messages from room %d older than %s under the %s", count, roomID, cutoff.Format(time.RFC3339), reason),
+		})
+	}
+}
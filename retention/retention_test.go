@@ -0,0 +1,44 @@
+package retention
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTTL(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"30m", 30 * time.Minute, true},
+		{"24h", 24 * time.Hour, true},
+		{"1h30m", 90 * time.Minute, true},
+		{"7d", 7 * 24 * time.Hour, true},
+		{"3650d", MaxTTL, true},
+		{"3651d", 0, false},
+		{"87601h", 0, false},
+		{"213504d", 0, false},
+		{"106752d", 0, false},
+		{"9223372036854775807d", 0, false},
+		{"99999999999999999999d", 0, false},
+		{"0d", 0, false},
+		{"-1d", 0, false},
+		{"0s", 0, false},
+		{"-5m", 0, false},
+		{"d", 0, false},
+		{"1.5d", 0, false},
+		{"7 days", 0, false},
+		{"", 0, false},
+		{"off", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseTTL(tt.value)
+		if tt.ok && (err != nil || got != tt.want) {
+			t.Errorf("ParseTTL(%q) = %s, %v; want %s", tt.value, got, err, tt.want)
+		}
+		if !tt.ok && err == nil {
+			t.Errorf("ParseTTL(%q) = %s, want an error", tt.value, got)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package safehttp
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"time"
+)
+
+// ErrPrivateAddress is returned when a request would connect to a non-public address
+var ErrPrivateAddress = errors.New("refusing to connect to a private address")
+
+// AllowPrivate lets outbound requests reach private and loopback addresses. It
+// is set from OUTBOUND_ALLOW_PRIVATE and is meant for development and tests only.
+var AllowPrivate bool
+
+// Address ranges that are not covered by the net.IP helpers
+var blockedNetworks = []*net.IPNet{
+	mustParseCIDR("0.0.0.0/8"),
+	mustParseCIDR("100.64.0.0/10"),
+	mustParseCIDR("192.0.0.0/24"),
+	mustParseCIDR("198.18.0.0/15"),
+	mustParseCIDR("240.0.0.0/4"),
+	mustParseCIDR("64:ff9b::/96"),
+}
+
+// Init reads the outbound request configuration
+func Init() {
+	AllowPrivate = os.Getenv("OUTBOUND_ALLOW_PRIVATE") == "true"
+	if AllowPrivate {
+		log.Println("Outbound requests may reach private addresses")
+	}
+}
+
+// NewClient returns an HTTP client for requests to user-supplied URLs. Its
+// dialer checks every address it connects to, after DNS resolution and on every
+// redirect, so a hostname can't be used to reach private ranges. At most
+// maxRedirects redirects to http or https URLs are followed; with none, the
+// redirect response itself is returned.
+func NewClient(timeout time.Duration, maxRedirects int) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext: (&net.Dialer{
+				Timeout: timeout,
+				Control: CheckAddress,
+			}).DialContext,
+			TLSHandshakeTimeout:   timeout,
+			ResponseHeaderTimeout: timeout,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       30 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if maxRedirects == 0 {
+				return http.ErrUseLastResponse
+			}
+			if len(via) > maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("refusing to follow a redirect to %s", req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+}
+
+// CheckAddress is a net.Dialer Control hook that rejects connections to
+// loopback, private, link-local, and other non-public addresses unless
+// AllowPrivate is set
+func CheckAddress(network, address string, _ syscall.RawConn) error {
+	if AllowPrivate {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !IsPublic(ip) {
+		return ErrPrivateAddress
+	}
+	return nil
+}
+
+// IsPublic reports whether an IP address is publicly routable
+func IsPublic(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+	for _, network := range blockedNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+func mustParseCIDR(cidr string) *net.IPNet {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
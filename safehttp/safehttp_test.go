@@ -0,0 +1,46 @@
+package safehttp
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestCheckAddress(t *testing.T) {
+	tests := []struct {
+		ip      string
+		allowed bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"fe80::1", false},
+		{"fc00::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"224.0.0.1", false},
+	}
+
+	AllowPrivate = false
+	for _, tt := range tests {
+		err := CheckAddress("tcp", net.JoinHostPort(tt.ip, "443"), nil)
+		if tt.allowed && err != nil {
+			t.Errorf("%s refused: %v", tt.ip, err)
+		}
+		if !tt.allowed && !errors.Is(err, ErrPrivateAddress) {
+			t.Errorf("%s allowed", tt.ip)
+		}
+	}
+
+	AllowPrivate = true
+	defer func() { AllowPrivate = false }()
+	if err := CheckAddress("tcp", "127.0.0.1:80", nil); err != nil {
+		t.Errorf("loopback refused with AllowPrivate set: %v", err)
+	}
+}
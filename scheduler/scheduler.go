@@ -0,0 +1,117 @@
+package scheduler
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/CUknot/network_backend/database"
+	"github.com/CUknot/network_backend/messaging"
+	"github.com/CUknot/network_backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// How often due messages are looked for
+	pollInterval = 5 * time.Second
+
+	// Most messages sent per transaction
+	batchSize = 20
+)
+
+var errNotMember = errors.New("the sender is no longer a member of the room")
+
+// Start sends scheduled messages as they fall due
+func Start() {
+	go func() {
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+
+		for {
+			run()
+			<-ticker.C
+		}
+	}()
+}
+
+// run sends every due message
+func run() {
+	for {
+		count, err := sendDue(time.Now())
+		if err != nil {
+			log.Printf("error sending scheduled messages: %v", err)
+			return
+		}
+		if count < batchSize {
+			return
+		}
+	}
+}
+
+// sendDue saves a batch of due messages in one transaction and publishes them
+// once it commits. Rows are locked with SKIP LOCKED so each is sent by exactly
+// one replica, and a message is only marked sent in the transaction that saves it.
+func sendDue(now time.Time) (int, error) {
+	var scheduled []models.ScheduledMessage
+	var saved []models.Message
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND send_at <= ?", models.ScheduledPending, now).
+			Order("send_at ASC").
+			Limit(batchSize).
+			Find(&scheduled).Error; err != nil {
+			return err
+		}
+
+		for _, item := range scheduled {
+			message := models.Message{
+				Content:     item.Content,
+				RoomID:      item.RoomID,
+				UserID:      item.UserID,
+				ClientMsgID: item.ClientMsgID,
+			}
+
+			// A savepoint per message keeps one failure from holding up the rest
+			sendErr := tx.Transaction(func(tx *gorm.DB) error {
+				var count int64
+				if err := tx.Model(&models.RoomUser{}).Where("room_id = ? AND user_id = ?", item.RoomID, item.UserID).Count(&count).Error; err != nil {
+					return err
+				}
+				if count == 0 {
+					return errNotMember
+				}
+
+				if err := messaging.Save(tx, &message); err != nil {
+					return err
+				}
+				return tx.Model(&models.ScheduledMessage{}).Where("id = ?", item.ID).Updates(map[string]interface{}{
+					"status":     models.ScheduledSent,
+					"message_id": message.ID,
+					"sent_at":    now,
+				}).Error
+			})
+			if sendErr != nil {
+				log.Printf("error sending scheduled message %d: %v", item.ID, sendErr)
+				if err := tx.Model(&models.ScheduledMessage{}).Where("id = ?", item.ID).Updates(map[string]interface{}{
+					"status":     models.ScheduledFailed,
+					"last_error": sendErr.Error(),
+				}).Error; err != nil {
+					return err
+				}
+				continue
+			}
+			saved = append(saved, message)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range saved {
+		messaging.Publish(&saved[i])
+	}
+	return len(scheduled), nil
+}
@@ -0,0 +1,69 @@
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps failure records in process memory, suitable for a single replica
+type MemoryStore struct {
+	mu        sync.Mutex
+	records   map[string]Record
+	lastSweep time.Time
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		records: make(map[string]Record),
+	}
+}
+
+// Attempt records a failure for a key if allow accepts its current record
+func (s *MemoryStore) Attempt(key string, now time.Time, resetAfter time.Duration, allow func(Record) bool) (Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Periodically drop records that can no longer affect any decision
+	if now.Sub(s.lastSweep) > resetAfter {
+		for k, r := range s.records {
+			if now.Sub(r.LastFailure) > resetAfter {
+				delete(s.records, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	record := s.records[key]
+	if now.Sub(record.LastFailure) > resetAfter {
+		record = Record{}
+	}
+	if !allow(record) {
+		return record, false, nil
+	}
+	record.Failures++
+	record.LastFailure = now
+	s.records[key] = record
+
+	return record, true, nil
+}
+
+// Forgive takes back one failure for a key
+func (s *MemoryStore) Forgive(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record, ok := s.records[key]; ok && record.Failures > 0 {
+		record.Failures--
+		s.records[key] = record
+	}
+	return nil
+}
+
+// Reset forgets a key
+func (s *MemoryStore) Reset(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
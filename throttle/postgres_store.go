@@ -0,0 +1,66 @@
+package throttle
+
+import (
+	"time"
+
+	"github.com/CUknot/network_backend/models"
+	"gorm.io/gorm"
+)
+
+// PostgresStore keeps failure records in the login_attempts table so all replicas share them
+type PostgresStore struct {
+	db *gorm.DB
+}
+
+// NewPostgresStore creates a store backed by db
+func NewPostgresStore(db *gorm.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// Attempt records a failure for a key if allow accepts its current record. The
+// upsert creates or locks the key's row, so attempts on every replica take
+// their turn until the transaction ends.
+func (s *PostgresStore) Attempt(key string, now time.Time, resetAfter time.Duration, allow func(Record) bool) (Record, bool, error) {
+	var record Record
+	var allowed bool
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var attempt models.LoginAttempt
+		if err := tx.Raw(`
+			INSERT INTO login_attempts (key, failures, last_failure_at) VALUES (@key, 0, @now)
+			ON CONFLICT (key) DO UPDATE SET
+				failures = CASE WHEN login_attempts.last_failure_at < @stale THEN 0 ELSE login_attempts.failures END
+			RETURNING key, failures, last_failure_at`,
+			map[string]interface{}{"key": key, "now": now, "stale": now.Add(-resetAfter)},
+		).Scan(&attempt).Error; err != nil {
+			return err
+		}
+
+		record = Record{Failures: attempt.Failures, LastFailure: attempt.LastFailureAt}
+		if allowed = allow(record); !allowed {
+			return nil
+		}
+
+		record = Record{Failures: record.Failures + 1, LastFailure: now}
+		return tx.Model(&models.LoginAttempt{}).Where("key = ?", key).Updates(map[string]interface{}{
+			"failures":        record.Failures,
+			"last_failure_at": now,
+		}).Error
+	})
+	if err != nil {
+		return Record{}, false, err
+	}
+
+	return record, allowed, nil
+}
+
+// Forgive takes back one failure for a key
+func (s *PostgresStore) Forgive(key string) error {
+	return s.db.Model(&models.LoginAttempt{}).
+		Where("key = ? AND failures > 0", key).
+		Update("failures", gorm.Expr("failures - 1")).Error
+}
+
+// Reset forgets a key
+func (s *PostgresStore) Reset(key string) error {
+	return s.db.Where("key = ?", key).Delete(&models.LoginAttempt{}).Error
+}
@@ -0,0 +1,112 @@
+package throttle
+
+import (
+	"time"
+)
+
+// Record is the failure history stored for a single key
+type Record struct {
+	Failures    int
+	LastFailure time.Time
+}
+
+// Store persists failure records so limits can be shared between replicas
+type Store interface {
+	// Attempt atomically loads the record for a key, starting over if the
+	// previous failure is older than resetAfter, and records a failure at now
+	// if allow accepts the record. It returns the record as it stands afterwards
+	// and whether the attempt was allowed.
+	Attempt(key string, now time.Time, resetAfter time.Duration, allow func(Record) bool) (Record, bool, error)
+
+	// Forgive takes back one failure recorded for a key
+	Forgive(key string) error
+
+	// Reset forgets all failures for a key
+	Reset(key string) error
+}
+
+// Policy describes how failures translate into backoff and lockout
+type Policy struct {
+	// Failures allowed before any delay is imposed
+	FreeAttempts int
+
+	// Delay after the first failure past FreeAttempts, doubled for each further failure
+	BaseDelay time.Duration
+
+	// Upper bound for the exponential backoff delay
+	MaxDelay time.Duration
+
+	// Failures after which the key is locked out
+	LockoutThreshold int
+
+	// How long a lockout lasts
+	LockoutDuration time.Duration
+
+	// Failures older than this are forgotten
+	ResetAfter time.Duration
+}
+
+// blockedUntil returns the time before which no further attempts are allowed
+func (p Policy) blockedUntil(r Record) time.Time {
+	if r.Failures >= p.LockoutThreshold {
+		return r.LastFailure.Add(p.LockoutDuration)
+	}
+	if r.Failures <= p.FreeAttempts {
+		return time.Time{}
+	}
+
+	delay := p.BaseDelay
+	for i := p.FreeAttempts + 1; i < r.Failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return r.LastFailure.Add(delay)
+}
+
+// Limiter applies a policy to keys stored in a store
+type Limiter struct {
+	store  Store
+	policy Policy
+	prefix string
+}
+
+// NewLimiter creates a limiter whose keys are namespaced by prefix
+func NewLimiter(store Store, policy Policy, prefix string) *Limiter {
+	return &Limiter{
+		store:  store,
+		policy: policy,
+		prefix: prefix,
+	}
+}
+
+// Attempt checks whether an attempt for key may go ahead at now and, if it
+// may, counts it as a failure straight away. Checking and counting in one step
+// stops parallel attempts from all passing the check before any is counted.
+// It returns how long to wait when the attempt is refused, and whether the
+// counted failure reaches the lockout threshold.
+func (l *Limiter) Attempt(key string, now time.Time) (time.Duration, bool, error) {
+	var wait time.Duration
+	record, allowed, err := l.store.Attempt(l.prefix+key, now, l.policy.ResetAfter, func(r Record) bool {
+		wait = l.policy.blockedUntil(r).Sub(now)
+		return wait <= 0
+	})
+	if err != nil {
+		return 0, false, err
+	}
+	if !allowed {
+		return wait, false, nil
+	}
+	return 0, record.Failures >= l.policy.LockoutThreshold, nil
+}
+
+// Forgive takes back the failure counted for an attempt that succeeded or never went ahead
+func (l *Limiter) Forgive(key string) error {
+	return l.store.Forgive(l.prefix + key)
+}
+
+// Reset clears the failure history for key
+func (l *Limiter) Reset(key string) error {
+	return l.store.Reset(l.prefix + key)
+}
@@ -0,0 +1,130 @@
+package throttle
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/CUknot/network_backend/database"
+	"github.com/CUknot/network_backend/internal/testdb"
+)
+
+var testPolicy = Policy{
+	FreeAttempts:     3,
+	BaseDelay:        time.Minute,
+	MaxDelay:         time.Hour,
+	LockoutThreshold: 6,
+	LockoutDuration:  time.Hour,
+	ResetAfter:       24 * time.Hour,
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, func() Store { return NewMemoryStore() })
+}
+
+func TestPostgresStore(t *testing.T) {
+	testdb.Open(t)
+	testStore(t, func() Store { return NewPostgresStore(database.DB) })
+}
+
+// testStore runs the limiter checks against a store backend
+func testStore(t *testing.T, newStore func() Store) {
+	// Keys are unique per run so a shared database needs no cleanup between runs
+	run := time.Now().UnixNano()
+	key := func(name string) string { return fmt.Sprintf("%s-%d", name, run) }
+
+	t.Run("parallel attempts are counted before the check", func(t *testing.T) {
+		limiter := NewLimiter(newStore(), testPolicy, "test:")
+		now := time.Now().Truncate(time.Second)
+
+		var allowed atomic.Int32
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				wait, _, err := limiter.Attempt(key("parallel"), now)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if wait == 0 {
+					allowed.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+
+		// The free attempts plus the one whose failure starts the backoff
+		if got, want := allowed.Load(), int32(testPolicy.FreeAttempts+1); got != want {
+			t.Errorf("%d parallel attempts allowed, want %d", got, want)
+		}
+	})
+
+	t.Run("backoff and lockout", func(t *testing.T) {
+		limiter := NewLimiter(newStore(), testPolicy, "test:")
+		now := time.Now().Truncate(time.Second)
+
+		for i := 1; i <= testPolicy.FreeAttempts+1; i++ {
+			if wait, _, err := limiter.Attempt(key("backoff"), now); err != nil || wait != 0 {
+				t.Fatalf("attempt %d: wait %s, err %v; want it allowed", i, wait, err)
+			}
+		}
+		if wait, _, _ := limiter.Attempt(key("backoff"), now.Add(time.Second)); wait != testPolicy.BaseDelay-time.Second {
+			t.Errorf("wait after the free attempts = %s, want %s", wait, testPolicy.BaseDelay-time.Second)
+		}
+
+		// Waiting out each delay lets the next attempt through until the lockout
+		var locked bool
+		for i := testPolicy.FreeAttempts + 2; i <= testPolicy.LockoutThreshold; i++ {
+			now = now.Add(testPolicy.MaxDelay)
+			wait, l, err := limiter.Attempt(key("backoff"), now)
+			if err != nil || wait != 0 {
+				t.Fatalf("attempt %d: wait %s, err %v; want it allowed", i, wait, err)
+			}
+			locked = l
+		}
+		if !locked {
+			t.Error("reaching the lockout threshold did not report a lockout")
+		}
+		if wait, _, _ := limiter.Attempt(key("backoff"), now.Add(time.Minute)); wait != testPolicy.LockoutDuration-time.Minute {
+			t.Errorf("wait during lockout = %s, want %s", wait, testPolicy.LockoutDuration-time.Minute)
+		}
+
+		// Old failures are forgotten
+		if wait, _, _ := limiter.Attempt(key("backoff"), now.Add(testPolicy.ResetAfter+time.Second)); wait != 0 {
+			t.Errorf("wait after ResetAfter = %s, want none", wait)
+		}
+	})
+
+	t.Run("forgive and reset", func(t *testing.T) {
+		limiter := NewLimiter(newStore(), testPolicy, "test:")
+		now := time.Now().Truncate(time.Second)
+
+		// Successful attempts that are forgiven never add up
+		for i := 0; i < testPolicy.LockoutThreshold*2; i++ {
+			wait, _, err := limiter.Attempt(key("forgive"), now)
+			if err != nil || wait != 0 {
+				t.Fatalf("attempt %d: wait %s, err %v; want it allowed", i+1, wait, err)
+			}
+			if err := limiter.Forgive(key("forgive")); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		for i := 0; i <= testPolicy.FreeAttempts; i++ {
+			limiter.Attempt(key("reset"), now)
+		}
+		if wait, _, _ := limiter.Attempt(key("reset"), now); wait == 0 {
+			t.Fatal("attempt after the free attempts was allowed")
+		}
+		if err := limiter.Reset(key("reset")); err != nil {
+			t.Fatal(err)
+		}
+		if wait, _, _ := limiter.Attempt(key("reset"), now); wait != 0 {
+			t.Errorf("wait after Reset = %s, want none", wait)
+		}
+	})
+}
@@ -0,0 +1,160 @@
+package unfurl
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/CUknot/network_backend/models"
+	"github.com/CUknot/network_backend/safehttp"
+	"golang.org/x/net/html"
+)
+
+const (
+	// Time allowed for a whole fetch, redirects included
+	fetchTimeout = 5 * time.Second
+
+	// Largest page read when looking for metadata
+	maxBodySize = 1 << 20
+
+	// Most redirects followed
+	maxRedirects = 3
+
+	// Longest values kept from a page
+	maxTitleLength       = 300
+	maxDescriptionLength = 1000
+)
+
+// Client fetches pages to unfurl
+var Client = safehttp.NewClient(fetchTimeout, maxRedirects)
+
+// fetch downloads a page and extracts its preview metadata
+func fetch(link string) (models.LinkPreview, error) {
+	req, err := http.NewRequest(http.MethodGet, link, nil)
+	if err != nil {
+		return models.LinkPreview{}, err
+	}
+	req.Header.Set("User-Agent", "network_backend-unfurl/1.0")
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	resp, err := Client.Do(req)
+	if err != nil {
+		return models.LinkPreview{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return models.LinkPreview{}, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return models.LinkPreview{}, fmt.Errorf("unsupported content type %q", mediaType)
+	}
+
+	preview := parse(io.LimitReader(resp.Body, maxBodySize), resp.Request.URL)
+	preview.URL = link
+	if preview.Title == "" && preview.Description == "" && preview.ImageURL == "" {
+		return preview, errors.New("no metadata found")
+	}
+	return preview, nil
+}
+
+// parse reads OpenGraph and Twitter card metadata, falling back to the page's
+// title and description, from the head of an HTML document
+func parse(body io.Reader, base *url.URL) models.LinkPreview {
+	meta := make(map[string]string)
+	var title string
+	inTitle := false
+
+	tokenizer := html.NewTokenizer(body)
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return preview(meta, title, base)
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := tokenizer.TagName()
+			switch string(name) {
+			case "body":
+				return preview(meta, title, base)
+			case "title":
+				inTitle = true
+			case "meta":
+				var key, content string
+				for hasAttr {
+					var attr, value []byte
+					attr, value, hasAttr = tokenizer.TagAttr()
+					switch string(attr) {
+					case "property", "name":
+						key = strings.ToLower(string(value))
+					case "content":
+						content = string(value)
+					}
+				}
+				if key != "" && meta[key] == "" {
+					meta[key] = strings.TrimSpace(content)
+				}
+			}
+		case html.TextToken:
+			if inTitle && title == "" {
+				title = strings.TrimSpace(string(tokenizer.Text()))
+			}
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			switch string(name) {
+			case "title":
+				inTitle = false
+			case "head":
+				return preview(meta, title, base)
+			}
+		}
+	}
+}
+
+// preview builds a preview from collected metadata
+func preview(meta map[string]string, title string, base *url.URL) models.LinkPreview {
+	first := func(keys ...string) string {
+		for _, key := range keys {
+			if meta[key] != "" {
+				return meta[key]
+			}
+		}
+		return ""
+	}
+
+	if t := first("og:title", "twitter:title"); t != "" {
+		title = t
+	}
+	return models.LinkPreview{
+		Title:       truncate(title, maxTitleLength),
+		Description: truncate(first("og:description", "twitter:description", "description"), maxDescriptionLength),
+		SiteName:    truncate(first("og:site_name", "application-name"), maxTitleLength),
+		ImageURL:    resolveImage(first("og:image:secure_url", "og:image", "twitter:image", "twitter:image:src"), base),
+	}
+}
+
+// resolveImage makes an image reference absolute and keeps it only if it is http or https
+func resolveImage(ref string, base *url.URL) string {
+	if ref == "" {
+		return ""
+	}
+	u, err := base.Parse(ref)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.String()) > maxURLLength {
+		return ""
+	}
+	return u.String()
+}
+
+// truncate shortens text to at most n runes
+func truncate(text string, n int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if utf8.RuneCountInString(text) <= n {
+		return text
+	}
+	return string([]rune(text)[:n-1]) + "…"
+}
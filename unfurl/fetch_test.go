@@ -0,0 +1,244 @@
+package unfurl
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CUknot/network_backend/models"
+	"github.com/CUknot/network_backend/safehttp"
+)
+
+// allowPrivate lets the test reach httptest servers on loopback
+func allowPrivate(t *testing.T) {
+	t.Helper()
+	safehttp.AllowPrivate = true
+	t.Cleanup(func() {
+		safehttp.AllowPrivate = false
+		Client.CloseIdleConnections()
+	})
+}
+
+// htmlServer serves page at / as HTML
+func htmlServer(t *testing.T, page string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, page)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestFetchMetadata(t *testing.T) {
+	allowPrivate(t)
+
+	tests := []struct {
+		name string
+		page string
+		want models.LinkPreview
+	}{
+		{
+			name: "open graph",
+			page: `<html><head><title>Fallback</title>
+				<meta property="og:title" content="OG title">
+				<meta property="og:description" content="OG description">
+				<meta property="og:site_name" content="Example">
+				<meta property="og:image" content="/images/card.png">
+				</head><body></body></html>`,
+			want: models.LinkPreview{Title: "OG title", Description: "OG description", SiteName: "Example", ImageURL: "/images/card.png"},
+		},
+		{
+			name: "twitter card",
+			page: `<html><head>
+				<meta name="twitter:title" content="Card title">
+				<meta name="twitter:description" content="Card description">
+				<meta name="twitter:image" content="https://cdn.example.com/card.jpg">
+				</head></html>`,
+			want: models.LinkPreview{Title: "Card title", Description: "Card description", ImageURL: "https://cdn.example.com/card.jpg"},
+		},
+		{
+			name: "title and description fallback",
+			page: `<html><head><title>  Plain   page </title><meta name="description" content="About this page"></head></html>`,
+			want: models.LinkPreview{Title: "Plain page", Description: "About this page"},
+		},
+		{
+			name: "open graph wins over twitter",
+			page: `<head><meta name="twitter:title" content="Twitter"><meta property="og:title" content="OG"></head>`,
+			want: models.LinkPreview{Title: "OG"},
+		},
+		{
+			name: "unsafe image scheme dropped",
+			page: `<head><meta property="og:title" content="T"><meta property="og:image" content="javascript:alert(1)"></head>`,
+			want: models.LinkPreview{Title: "T"},
+		},
+		{
+			name: "metadata in body ignored",
+			page: `<html><head><title>Head</title></head><body><meta property="og:title" content="Body"></body></html>`,
+			want: models.LinkPreview{Title: "Head"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := htmlServer(t, tt.page)
+
+			got, err := fetch(srv.URL + "/page")
+			if err != nil {
+				t.Fatalf("fetch: %v", err)
+			}
+
+			want := tt.want
+			want.URL = srv.URL + "/page"
+			if strings.HasPrefix(want.ImageURL, "/") {
+				want.ImageURL = srv.URL + want.ImageURL
+			}
+			if got != want {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestFetchTruncatesLongValues(t *testing.T) {
+	allowPrivate(t)
+	srv := htmlServer(t, `<head><meta property="og:title" content="`+strings.Repeat("a", 1000)+`"></head>`)
+
+	got, err := fetch(srv.URL)
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if n := len([]rune(got.Title)); n != maxTitleLength {
+		t.Errorf("title has %d runes, want %d", n, maxTitleLength)
+	}
+}
+
+func TestFetchBodyLimit(t *testing.T) {
+	allowPrivate(t)
+
+	// Metadata past the first MiB is never read
+	padding := "<!--" + strings.Repeat("x", maxBodySize) + "-->"
+	srv := htmlServer(t, `<html><head>`+padding+`<meta property="og:title" content="Too late"></head></html>`)
+	if _, err := fetch(srv.URL); err == nil {
+		t.Error("fetch found metadata past the body limit")
+	}
+
+	// Metadata inside the limit is still found on a large page
+	srv = htmlServer(t, `<html><head><meta property="og:title" content="In time">`+padding+`</head></html>`)
+	got, err := fetch(srv.URL)
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if got.Title != "In time" {
+		t.Errorf("title = %q, want %q", got.Title, "In time")
+	}
+}
+
+func TestFetchTimeout(t *testing.T) {
+	allowPrivate(t)
+
+	client := Client
+	Client = safehttp.NewClient(100*time.Millisecond, maxRedirects)
+	t.Cleanup(func() { Client = client })
+
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { close(release) })
+
+	start := time.Now()
+	if _, err := fetch(srv.URL); err == nil {
+		t.Fatal("fetch of a hanging server succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("fetch took %v, want it to give up after the timeout", elapsed)
+	}
+}
+
+func TestFetchRedirectLimit(t *testing.T) {
+	allowPrivate(t)
+
+	// /hop/n redirects to /hop/n-1 and /hop/0 serves the page
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hop/", func(w http.ResponseWriter, r *http.Request) {
+		var n int
+		fmt.Sscanf(strings.TrimPrefix(r.URL.Path, "/hop/"), "%d", &n)
+		if n > 0 {
+			http.Redirect(w, r, fmt.Sprintf("/hop/%d", n-1), http.StatusFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<head><title>Landed</title></head>`)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	got, err := fetch(fmt.Sprintf("%s/hop/%d", srv.URL, maxRedirects))
+	if err != nil {
+		t.Fatalf("fetch with %d redirects: %v", maxRedirects, err)
+	}
+	if got.Title != "Landed" {
+		t.Errorf("title = %q, want %q", got.Title, "Landed")
+	}
+
+	if _, err := fetch(fmt.Sprintf("%s/hop/%d", srv.URL, maxRedirects+1)); err == nil {
+		t.Errorf("fetch followed more than %d redirects", maxRedirects)
+	}
+}
+
+func TestFetchRejectsNonHTML(t *testing.T) {
+	allowPrivate(t)
+
+	for _, contentType := range []string{"application/json", "image/png", "text/plain", ""} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", contentType)
+			fmt.Fprint(w, `<head><title>Not a page</title></head>`)
+		}))
+		if _, err := fetch(srv.URL); err == nil {
+			t.Errorf("fetch accepted content type %q", contentType)
+		}
+		srv.Close()
+	}
+}
+
+func TestFetchRejectsErrorStatus(t *testing.T) {
+	allowPrivate(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `<head><title>Not found</title></head>`)
+	}))
+	t.Cleanup(srv.Close)
+
+	if _, err := fetch(srv.URL); err == nil {
+		t.Error("fetch accepted a 404 page")
+	}
+}
+
+func TestFetchRefusesLoopback(t *testing.T) {
+	safehttp.AllowPrivate = false
+	Client.CloseIdleConnections()
+
+	var hits int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<head><title>Internal</title></head>`)
+	}))
+	t.Cleanup(srv.Close)
+
+	_, err := fetch(srv.URL)
+	if !errors.Is(err, safehttp.ErrPrivateAddress) {
+		t.Errorf("fetch error = %v, want %v", err, safehttp.ErrPrivateAddress)
+	}
+	if hits != 0 {
+		t.Errorf("loopback server received %d requests", hits)
+	}
+}
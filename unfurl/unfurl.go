@@ -0,0 +1,127 @@
+package unfurl
+
+import (
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/CUknot/network_backend/database"
+	"github.com/CUknot/network_backend/models"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// Most links previewed per message
+	maxLinks = 3
+
+	// Longest URL that is unfurled
+	maxURLLength = 2048
+
+	// How long previews and failures are cached
+	cacheTTL   = 24 * time.Hour
+	failureTTL = time.Hour
+
+	// Most pages fetched at the same time
+	maxConcurrentFetches = 4
+)
+
+// Limits how many fetches run at once
+var fetchSlots = make(chan struct{}, maxConcurrentFetches)
+
+// Message previews the links in a message and, if there are any, stores them
+// with the message and returns the updated message
+func Message(message models.Message) (*models.Message, bool) {
+	// Polls are updated through their own events
+	if message.Body == nil || message.Type == models.MessagePoll {
+		return nil, false
+	}
+
+	var previews []models.LinkPreview
+	for _, link := range links(message.Body.Links()) {
+		if preview, ok := Lookup(link); ok {
+			previews = append(previews, preview)
+		}
+	}
+	if len(previews) == 0 && len(message.Previews) == 0 {
+		return nil, false
+	}
+
+	// Skip the update if the message was edited or deleted while the links were fetched
+	result := database.DB.Model(&models.Message{}).Where("id = ? AND content = ?", message.ID, message.Content).
+		Select("previews").Updates(&models.Message{Previews: previews})
+	if result.Error != nil {
+		log.Printf("error saving link previews for message %d: %v", message.ID, result.Error)
+		return nil, false
+	}
+	if result.RowsAffected == 0 {
+		return nil, false
+	}
+
+	var updated models.Message
+	if err := database.DB.Preload("User").Preload("Mentions").First(&updated, message.ID).Error; err != nil {
+		return nil, false
+	}
+	return &updated, true
+}
+
+// Lookup returns the preview for a link from the cache, fetching it if the
+// cached copy is missing or stale
+func Lookup(link string) (models.LinkPreview, bool) {
+	var cached models.LinkPreviewCache
+	err := database.DB.Where("url = ?", link).First(&cached).Error
+	if err == nil && fresh(cached, time.Now()) {
+		return cached.Preview(), !cached.Failed
+	}
+
+	fetchSlots <- struct{}{}
+	preview, fetchErr := fetch(link)
+	<-fetchSlots
+
+	cached = models.LinkPreviewCache{
+		URL:         link,
+		Title:       preview.Title,
+		Description: preview.Description,
+		SiteName:    preview.SiteName,
+		ImageURL:    preview.ImageURL,
+		Failed:      fetchErr != nil,
+		FetchedAt:   time.Now(),
+	}
+	if err := database.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "url"}},
+		DoUpdates: clause.AssignmentColumns([]string{"title", "description", "site_name", "image_url", "failed", "fetched_at"}),
+	}).Create(&cached).Error; err != nil {
+		log.Printf("error caching link preview for %s: %v", link, err)
+	}
+
+	if fetchErr != nil {
+		return models.LinkPreview{}, false
+	}
+	return cached.Preview(), true
+}
+
+// fresh reports whether a cached preview, or a cached failure, can still be used
+func fresh(cached models.LinkPreviewCache, now time.Time) bool {
+	ttl := cacheTTL
+	if cached.Failed {
+		ttl = failureTTL
+	}
+	return now.Sub(cached.FetchedAt) < ttl
+}
+
+// links picks the distinct http and https links worth previewing
+func links(candidates []string) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, link := range candidates {
+		u, err := url.Parse(link)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(link) > maxURLLength || seen[link] {
+			continue
+		}
+		seen[link] = true
+		result = append(result, link)
+		if len(result) == maxLinks {
+			break
+		}
+	}
+	return result
+}
@@ -0,0 +1,108 @@
+package unfurl
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/CUknot/network_backend/database"
+	"github.com/CUknot/network_backend/internal/testdb"
+	"github.com/CUknot/network_backend/models"
+)
+
+func TestLinks(t *testing.T) {
+	got := links([]string{
+		"https://a.example",
+		"https://a.example",
+		"mailto:someone@example.com",
+		"ftp://files.example",
+		"http://b.example",
+		"https://c.example",
+		"https://d.example",
+	})
+	want := []string{"https://a.example", "http://b.example", "https://c.example"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("links = %v, want %v", got, want)
+	}
+}
+
+func TestFresh(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name   string
+		cached models.LinkPreviewCache
+		want   bool
+	}{
+		{"recent preview", models.LinkPreviewCache{FetchedAt: now.Add(-time.Hour)}, true},
+		{"stale preview", models.LinkPreviewCache{FetchedAt: now.Add(-cacheTTL - time.Minute)}, false},
+		{"recent failure", models.LinkPreviewCache{Failed: true, FetchedAt: now.Add(-time.Minute)}, true},
+		{"stale failure", models.LinkPreviewCache{Failed: true, FetchedAt: now.Add(-failureTTL - time.Minute)}, false},
+	}
+	for _, tt := range tests {
+		if got := fresh(tt.cached, now); got != tt.want {
+			t.Errorf("%s: fresh = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLookupCache(t *testing.T) {
+	testdb.Open(t)
+	allowPrivate(t)
+
+	var hits atomic.Int32
+	failing := atomic.Bool{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<head><meta property="og:title" content="Cached"></head>`)
+	}))
+	t.Cleanup(srv.Close)
+
+	link := fmt.Sprintf("%s/%d", srv.URL, time.Now().UnixNano())
+	t.Cleanup(func() { database.DB.Where("url = ?", link).Delete(&models.LinkPreviewCache{}) })
+
+	// A fetched preview is served from the cache afterwards
+	for i := 0; i < 2; i++ {
+		preview, ok := Lookup(link)
+		if !ok || preview.Title != "Cached" {
+			t.Fatalf("lookup %d = %+v, %v", i, preview, ok)
+		}
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("server hit %d times, want 1", n)
+	}
+
+	// Once stale it is fetched again, and a failure is cached too
+	failing.Store(true)
+	age := func(by time.Duration) {
+		database.DB.Model(&models.LinkPreviewCache{}).Where("url = ?", link).Update("fetched_at", time.Now().Add(-by))
+	}
+	age(cacheTTL + time.Minute)
+	if _, ok := Lookup(link); ok {
+		t.Error("lookup of a failing page succeeded")
+	}
+	if _, ok := Lookup(link); ok {
+		t.Error("cached failure was returned as a preview")
+	}
+	if n := hits.Load(); n != 2 {
+		t.Errorf("server hit %d times, want 2", n)
+	}
+
+	// A failure is retried after failureTTL
+	failing.Store(false)
+	age(failureTTL + time.Minute)
+	if preview, ok := Lookup(link); !ok || preview.Title != "Cached" {
+		t.Errorf("lookup after failure expired = %+v, %v", preview, ok)
+	}
+	if n := hits.Load(); n != 3 {
+		t.Errorf("server hit %d times, want 3", n)
+	}
+}
@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"errors"
+	"time"
+
+	"github.com/CUknot/network_backend/database"
+	"github.com/CUknot/network_backend/models"
+)
+
+// How often a session's last-seen time is written back to the database
+const sessionTouchInterval = time.Minute
+
+// AuthenticateToken validates a JWT token and loads the live session it was issued for
+func AuthenticateToken(tokenString string) (*models.Session, error) {
+	userID, sessionID, err := ParseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	// A revoked session no longer exists, even if its token has not expired
+	var session models.Session
+	if err := database.DB.Where("id = ? AND user_id = ?", sessionID, userID).First(&session).Error; err != nil {
+		return nil, errors.New("session has been revoked")
+	}
+
+	// Record activity without writing on every request
+	now := time.Now()
+	if now.Sub(session.LastSeenAt) > sessionTouchInterval {
+		database.DB.Model(&session).Update("last_seen_at", now)
+	}
+
+	return &session, nil
+}
@@ -1,31 +1,62 @@
 package utils
 
 import (
+	"errors"
 	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 )
 
-// GenerateToken creates a new JWT token for a user
-func GenerateToken(userID uint) (string, error) {
-	// Get JWT secret from environment
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		jwtSecret = "your-secret-key" // Default secret (not recommended for production)
-	}
-
+// GenerateToken creates a new JWT token for a user session
+func GenerateToken(userID, sessionID uint) (string, error) {
 	// Create token with claims
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": userID,
-		"exp":     time.Now().Add(time.Hour * 24 * 7).Unix(), // Token expires in 7 days
+		"user_id":    userID,
+		"session_id": sessionID,
+		"exp":        time.Now().Add(time.Hour * 24 * 7).Unix(), // Token expires in 7 days
 	})
 
 	// Sign and get the complete encoded token as a string
-	tokenString, err := token.SignedString([]byte(jwtSecret))
+	tokenString, err := token.SignedString([]byte(jwtSecret()))
 	if err != nil {
 		return "", err
 	}
 
 	return tokenString, nil
 }
+
+// ParseToken validates a JWT token and returns the user and session IDs it carries
+func ParseToken(tokenString string) (uint, uint, error) {
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return []byte(jwtSecret()), nil
+	})
+	if err != nil || !token.Valid {
+		return 0, 0, errors.New("invalid or expired token")
+	}
+
+	userID, ok := claims["user_id"].(float64)
+	if !ok {
+		return 0, 0, errors.New("token is missing user_id")
+	}
+	sessionID, ok := claims["session_id"].(float64)
+	if !ok {
+		return 0, 0, errors.New("token is missing session_id")
+	}
+
+	return uint(userID), uint(sessionID), nil
+}
+
+// jwtSecret returns the secret used to sign and verify tokens
+func jwtSecret() string {
+	// Get JWT secret from environment
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		secret = "your-secret-key" // Default secret (not recommended for production)
+	}
+	return secret
+}
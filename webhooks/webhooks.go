@@ -0,0 +1,97 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/CUknot/network_backend/database"
+	"github.com/CUknot/network_backend/models"
+)
+
+// Envelope is the JSON body of every delivery
+type Envelope struct {
+	Event     string      `json:"event"`
+	RoomID    uint        `json:"room_id"`
+	Timestamp time.Time   `json:"timestamp"`
+	Data      interface{} `json:"data"`
+}
+
+// Dispatch queues an event for every active webhook of a room that subscribes to it
+func Dispatch(roomID uint, event string, data interface{}) {
+	var hooks []models.Webhook
+	if err := database.DB.Where("room_id = ? AND active", roomID).Find(&hooks).Error; err != nil {
+		log.Printf("error loading webhooks for room %d: %v", roomID, err)
+		return
+	}
+
+	var payload []byte
+	now := time.Now()
+	for _, hook := range hooks {
+		if !hook.Subscribes(event) {
+			continue
+		}
+
+		// Every subscriber receives the same body
+		if payload == nil {
+			var err error
+			payload, err = json.Marshal(Envelope{Event: event, RoomID: roomID, Timestamp: now, Data: data})
+			if err != nil {
+				log.Printf("error marshaling %s webhook payload: %v", event, err)
+				return
+			}
+		}
+
+		delivery := models.WebhookDelivery{
+			WebhookID:     hook.ID,
+			Event:         event,
+			Payload:       string(payload),
+			Status:        models.DeliveryPending,
+			NextAttemptAt: now,
+		}
+		if err := database.DB.Create(&delivery).Error; err != nil {
+			log.Printf("error queueing %s delivery for webhook %d: %v", event, hook.ID, err)
+		}
+	}
+
+	if payload != nil {
+		wake()
+	}
+}
+
+// MessageData is the data of a message event
+func MessageData(message models.Message) map[string]interface{} {
+	return map[string]interface{}{
+		"message": map[string]interface{}{
+			"id":         message.ID,
+			"room_id":    message.RoomID,
+			"content":    message.Content,
+			"body":       message.Body,
+			"created_at": message.CreatedAt,
+			"user":       UserData(message.User),
+		},
+	}
+}
+
+// UserData is the public view of a user included in events
+func UserData(user models.User) map[string]interface{} {
+	return map[string]interface{}{
+		"id":       user.ID,
+		"username": user.Username,
+		"tag":      user.Tag,
+		"handle":   user.Handle(),
+	}
+}
+
+// Sign computes the signature sent in the X-Webhook-Signature header. Receivers
+// recompute it over the X-Webhook-Timestamp header and the raw body.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
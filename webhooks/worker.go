@@ -0,0 +1,224 @@
+package webhooks
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/CUknot/network_backend/database"
+	"github.com/CUknot/network_backend/models"
+	"github.com/CUknot/network_backend/safehttp"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// How often the queue is polled for due deliveries
+	pollInterval = 5 * time.Second
+
+	// Most deliveries claimed per poll
+	batchSize = 20
+
+	// How long a claimed delivery is hidden from other workers while it is attempted
+	claimTimeout = time.Minute
+
+	// Retry schedule: baseDelay doubled after every failed attempt, up to maxDelay
+	baseDelay   = 30 * time.Second
+	maxDelay    = time.Hour
+	maxAttempts = 8
+
+	// A webhook is disabled after this many failed attempts in a row
+	disableThreshold = 20
+
+	// Most bytes of a response body recorded in the delivery log
+	maxErrorBody = 512
+)
+
+// Client is the HTTP client used to reach webhook receivers. It refuses private
+// addresses and doesn't follow redirects, which count as failed deliveries.
+var Client = safehttp.NewClient(10*time.Second, 0)
+
+// wakeup lets Dispatch start a delivery run without waiting for the next poll
+var wakeup = make(chan struct{}, 1)
+
+// Start delivers queued webhook events in the background
+func Start() {
+	go func() {
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+
+		for {
+			run()
+			select {
+			case <-ticker.C:
+			case <-wakeup:
+			}
+		}
+	}()
+}
+
+// wake asks the worker to check the queue now
+func wake() {
+	select {
+	case wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// run attempts every due delivery until the queue is drained
+func run() {
+	for {
+		deliveries, err := claim(time.Now())
+		if err != nil {
+			log.Printf("error claiming webhook deliveries: %v", err)
+			return
+		}
+		for _, delivery := range deliveries {
+			attempt(delivery)
+		}
+		if len(deliveries) < batchSize {
+			return
+		}
+	}
+}
+
+// claim locks a batch of due deliveries and pushes their next attempt back so
+// that no other replica picks them up while they are in flight
+func claim(now time.Time) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.DeliveryPending, now).
+			Order("next_attempt_at ASC").
+			Limit(batchSize).
+			Find(&deliveries).Error; err != nil {
+			return err
+		}
+		if len(deliveries) == 0 {
+			return nil
+		}
+
+		ids := make([]uint, len(deliveries))
+		for i, delivery := range deliveries {
+			ids[i] = delivery.ID
+		}
+		return tx.Model(&models.WebhookDelivery{}).Where("id IN ?", ids).Update("next_attempt_at", now.Add(claimTimeout)).Error
+	})
+	return deliveries, err
+}
+
+// attempt sends a delivery once and records the outcome
+func attempt(delivery models.WebhookDelivery) {
+	var hook models.Webhook
+	if err := database.DB.First(&hook, delivery.WebhookID).Error; err != nil || !hook.Active {
+		database.DB.Model(&delivery).Updates(map[string]interface{}{
+			"status":     models.DeliveryFailed,
+			"last_error": "webhook is disabled or deleted",
+		})
+		return
+	}
+
+	status, err := send(hook, delivery)
+	now := time.Now()
+	attempts := delivery.Attempts + 1
+
+	if err == nil {
+		database.DB.Model(&delivery).Updates(map[string]interface{}{
+			"status":          models.DeliverySucceeded,
+			"attempts":        attempts,
+			"response_status": status,
+			"last_error":      "",
+			"delivered_at":    now,
+		})
+		database.DB.Model(&models.Webhook{}).Where("id = ?", hook.ID).Update("consecutive_failures", 0)
+		return
+	}
+
+	database.DB.Model(&delivery).Updates(retryUpdates(attempts, status, err, now))
+
+	recordFailure(hook.ID, now)
+}
+
+// retryUpdates returns the changes recorded on a delivery after its attempts-th
+// failed attempt: another try after a backoff, or giving up after maxAttempts
+func retryUpdates(attempts, status int, err error, now time.Time) map[string]interface{} {
+	updates := map[string]interface{}{
+		"attempts":        attempts,
+		"response_status": status,
+		"last_error":      err.Error(),
+	}
+	if attempts >= maxAttempts {
+		updates["status"] = models.DeliveryFailed
+	} else {
+		updates["next_attempt_at"] = now.Add(backoff(attempts))
+	}
+	return updates
+}
+
+// recordFailure counts a failed attempt against a webhook and disables it once
+// it keeps failing
+func recordFailure(hookID uint, now time.Time) {
+	database.DB.Model(&models.Webhook{}).Where("id = ?", hookID).
+		Update("consecutive_failures", gorm.Expr("consecutive_failures + 1"))
+
+	result := database.DB.Model(&models.Webhook{}).
+		Where("id = ? AND active AND consecutive_failures >= ?", hookID, disableThreshold).
+		Updates(map[string]interface{}{"active": false, "disabled_at": now})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return
+	}
+
+	log.Printf("webhook %d disabled after %d failed deliveries", hookID, disableThreshold)
+	database.DB.Model(&models.WebhookDelivery{}).
+		Where("webhook_id = ? AND status = ?", hookID, models.DeliveryPending).
+		Updates(map[string]interface{}{
+			"status":     models.DeliveryFailed,
+			"last_error": "webhook disabled after repeated failures",
+		})
+}
+
+// send POSTs a signed delivery and returns the receiver's status code
+func send(hook models.Webhook, delivery models.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "network_backend-webhooks")
+	req.Header.Set("X-Webhook-Event", delivery.Event)
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", Sign(hook.Secret, timestamp, body))
+
+	resp, err := Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		excerpt, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return resp.StatusCode, fmt.Errorf("receiver returned %s: %s", resp.Status, excerpt)
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorBody))
+	return resp.StatusCode, nil
+}
+
+// backoff returns the delay before the next attempt after a number of failed attempts
+func backoff(attempts int) time.Duration {
+	delay := baseDelay
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= maxDelay {
+			return maxDelay
+		}
+	}
+	return delay
+}
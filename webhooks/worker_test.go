@@ -0,0 +1,222 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/CUknot/network_backend/database"
+	"github.com/CUknot/network_backend/internal/testdb"
+	"github.com/CUknot/network_backend/models"
+	"github.com/CUknot/network_backend/safehttp"
+)
+
+// allowPrivate lets the test reach httptest servers on loopback
+func allowPrivate(t *testing.T) {
+	t.Helper()
+	safehttp.AllowPrivate = true
+	t.Cleanup(func() {
+		safehttp.AllowPrivate = false
+		Client.CloseIdleConnections()
+	})
+}
+
+func TestSendSignsDeliveries(t *testing.T) {
+	allowPrivate(t)
+
+	const secret = "test-secret"
+	payload := `{"event":"message.created","data":{}}`
+
+	var got *http.Request
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+	}))
+	t.Cleanup(srv.Close)
+
+	hook := models.Webhook{URL: srv.URL, Secret: secret}
+	delivery := models.WebhookDelivery{ID: 42, Event: models.EventMessageCreated, Payload: payload}
+	before := time.Now().Unix()
+	status, err := send(hook, delivery)
+	if err != nil || status != http.StatusOK {
+		t.Fatalf("send = %d, %v", status, err)
+	}
+
+	if string(body) != payload {
+		t.Errorf("body = %s, want %s", body, payload)
+	}
+	if h := got.Header.Get("X-Webhook-Event"); h != models.EventMessageCreated {
+		t.Errorf("X-Webhook-Event = %q", h)
+	}
+	if h := got.Header.Get("X-Webhook-Delivery"); h != "42" {
+		t.Errorf("X-Webhook-Delivery = %q", h)
+	}
+
+	timestamp := got.Header.Get("X-Webhook-Timestamp")
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || ts < before || ts > time.Now().Unix() {
+		t.Errorf("X-Webhook-Timestamp = %q, want the time of sending", timestamp)
+	}
+
+	// The receiver can recompute the signature over the timestamp and raw body
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "." + string(body)))
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if h := got.Header.Get("X-Webhook-Signature"); h != want {
+		t.Errorf("X-Webhook-Signature = %q, want %q", h, want)
+	}
+}
+
+func TestSendFailures(t *testing.T) {
+	allowPrivate(t)
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		status  int
+	}{
+		{"server error", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusInternalServerError) }, http.StatusInternalServerError},
+		{"redirect not followed", func(w http.ResponseWriter, r *http.Request) { http.Redirect(w, r, "/elsewhere", http.StatusFound) }, http.StatusFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(tt.handler)
+			t.Cleanup(srv.Close)
+
+			status, err := send(models.Webhook{URL: srv.URL}, models.WebhookDelivery{Payload: "{}"})
+			if err == nil || status != tt.status {
+				t.Errorf("send = %d, %v; want %d and an error", status, err, tt.status)
+			}
+		})
+	}
+}
+
+func TestSendRefusesLoopback(t *testing.T) {
+	safehttp.AllowPrivate = false
+	Client.CloseIdleConnections()
+
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { hits.Add(1) }))
+	t.Cleanup(srv.Close)
+
+	if _, err := send(models.Webhook{URL: srv.URL}, models.WebhookDelivery{Payload: "{}"}); !errors.Is(err, safehttp.ErrPrivateAddress) {
+		t.Errorf("send error = %v, want %v", err, safehttp.ErrPrivateAddress)
+	}
+	if hits.Load() != 0 {
+		t.Error("loopback receiver was reached")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	want := []time.Duration{
+		30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute,
+		16 * time.Minute, 32 * time.Minute, time.Hour, time.Hour,
+	}
+	for i, delay := range want {
+		if got := backoff(i + 1); got != delay {
+			t.Errorf("backoff(%d) = %v, want %v", i+1, got, delay)
+		}
+	}
+}
+
+func TestRetryUpdates(t *testing.T) {
+	now := time.Now()
+	sendErr := errors.New("receiver returned 500")
+
+	updates := retryUpdates(1, http.StatusInternalServerError, sendErr, now)
+	if updates["next_attempt_at"] != now.Add(baseDelay) {
+		t.Errorf("next_attempt_at = %v, want %v", updates["next_attempt_at"], now.Add(baseDelay))
+	}
+	if _, ok := updates["status"]; ok {
+		t.Error("delivery given up after the first attempt")
+	}
+	if updates["last_error"] != sendErr.Error() || updates["response_status"] != http.StatusInternalServerError {
+		t.Errorf("failure not recorded: %v", updates)
+	}
+
+	updates = retryUpdates(maxAttempts, http.StatusInternalServerError, sendErr, now)
+	if updates["status"] != models.DeliveryFailed {
+		t.Errorf("status after %d attempts = %v, want %s", maxAttempts, updates["status"], models.DeliveryFailed)
+	}
+	if _, ok := updates["next_attempt_at"]; ok {
+		t.Error("delivery rescheduled after its last attempt")
+	}
+}
+
+func TestAttemptRetriesAndDisables(t *testing.T) {
+	testdb.Open(t)
+	allowPrivate(t)
+
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(srv.Close)
+
+	hook := models.Webhook{URL: srv.URL, Secret: "secret", Events: []string{models.EventMessageCreated}, Active: true}
+	if err := database.DB.Create(&hook).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		database.DB.Where("webhook_id = ?", hook.ID).Delete(&models.WebhookDelivery{})
+		database.DB.Delete(&hook)
+	})
+
+	newDelivery := func() models.WebhookDelivery {
+		delivery := models.WebhookDelivery{
+			WebhookID:     hook.ID,
+			Event:         models.EventMessageCreated,
+			Payload:       "{}",
+			Status:        models.DeliveryPending,
+			NextAttemptAt: time.Now(),
+		}
+		if err := database.DB.Create(&delivery).Error; err != nil {
+			t.Fatal(err)
+		}
+		return delivery
+	}
+
+	// A failed attempt is rescheduled after the backoff
+	delivery := newDelivery()
+	start := time.Now()
+	attempt(delivery)
+	database.DB.First(&delivery, delivery.ID)
+	if delivery.Status != models.DeliveryPending || delivery.Attempts != 1 || delivery.ResponseStatus != http.StatusServiceUnavailable {
+		t.Errorf("after one failure: %+v", delivery)
+	}
+	if wait := delivery.NextAttemptAt.Sub(start); wait < baseDelay || wait > baseDelay+time.Minute {
+		t.Errorf("next attempt in %v, want about %v", wait, baseDelay)
+	}
+
+	// Enough failures in a row disable the webhook and fail what is still queued
+	queued := newDelivery()
+	for i := 1; i < disableThreshold; i++ {
+		attempt(newDelivery())
+	}
+	database.DB.First(&hook, hook.ID)
+	if hook.Active || hook.DisabledAt == nil || hook.ConsecutiveFailures != disableThreshold {
+		t.Errorf("webhook after %d failures: active=%v disabled_at=%v failures=%d",
+			disableThreshold, hook.Active, hook.DisabledAt, hook.ConsecutiveFailures)
+	}
+	database.DB.First(&queued, queued.ID)
+	if queued.Status != models.DeliveryFailed {
+		t.Errorf("queued delivery status = %s, want %s", queued.Status, models.DeliveryFailed)
+	}
+
+	// A disabled webhook isn't called again
+	before := hits.Load()
+	attempt(newDelivery())
+	if hits.Load() != before {
+		t.Error("disabled webhook was called")
+	}
+}
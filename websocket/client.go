@@ -2,11 +2,15 @@ package websocket
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
+	"math"
 	"strconv"
 	"sync"
 	"time"
 
+	"github.com/CUknot/network_backend/database"
+	"github.com/CUknot/network_backend/models"
 	"github.com/gorilla/websocket"
 )
 
@@ -24,14 +28,23 @@ const (
 	maxMessageSize = 10000
 )
 
+// MessageHandler saves and publishes a chat message sent over a connection. When
+// it is not set, messages are relayed to the room without being stored.
+var MessageHandler func(userID, roomID uint, content, clientMsgID string) error
+
 // Client represents a connected websocket client
 type Client struct {
-	hub      *Hub
-	conn     *websocket.Conn
-	send     chan []byte
-	userID   uint
-	rooms    map[uint]bool
-	roomsMux sync.RWMutex
+	hub       *Hub
+	conn      *websocket.Conn
+	send      chan []byte
+	userID    uint
+	sessionID uint
+	rooms     map[uint]bool
+	roomsMux  sync.RWMutex
+	closed    bool
+	blocked   map[uint]bool
+	blockMux  sync.RWMutex
+	autoJoin  bool
 }
 
 // Message represents a websocket message
@@ -65,24 +78,70 @@ func (c *Client) readPump() {
 
 		var msg Message
 		if err := json.Unmarshal(message, &msg); err != nil {
-			log.Printf("error unmarshaling message: %v", err)
+			c.sendError("invalid_message", "Message must be a JSON object with a type and payload")
 			continue
 		}
 
 		switch msg.Type {
 		case "join_room":
-			if roomID, ok := msg.Payload.(string); ok {
-				roomIDUint := parseRoomID(roomID)
-				c.joinRoom(roomIDUint)
+			roomID, err := parseRoomID(msg.Payload)
+			if err != nil {
+				c.sendError("invalid_room_id", err.Error())
+				continue
+			}
+			if !isRoomMember(c.userID, roomID) {
+				c.sendError("not_a_member", "You don't have access to this room")
+				continue
 			}
+			c.joinRoom(roomID)
 		case "leave_room":
-			if roomID, ok := msg.Payload.(string); ok {
-				roomIDUint := parseRoomID(roomID)
-				c.leaveRoom(roomIDUint)
+			roomID, err := parseRoomID(msg.Payload)
+			if err != nil {
+				c.sendError("invalid_room_id", err.Error())
+				continue
 			}
+			c.leaveRoom(roomID)
 		case "message":
-			// Handle message in the hub
-			c.hub.broadcast <- message
+			if MessageHandler == nil {
+				// Relay the message to the room as it is
+				c.hub.broadcast <- clientMessage{client: c, data: message}
+				continue
+			}
+
+			var payload struct {
+				RoomID      interface{} `json:"room_id"`
+				Content     string      `json:"content"`
+				ClientMsgID string      `json:"client_msg_id"`
+			}
+			if err := remarshal(msg.Payload, &payload); err != nil || payload.Content == "" || len(payload.ClientMsgID) > 64 {
+				c.sendError("invalid_message", "Message payload must have a room_id, content, and at most a 64 character client_msg_id")
+				continue
+			}
+			roomID, err := parseRoomID(payload.RoomID)
+			if err != nil {
+				c.sendError("invalid_room_id", err.Error())
+				continue
+			}
+			if err := MessageHandler(c.userID, roomID, payload.Content, payload.ClientMsgID); err != nil {
+				c.sendError("message_failed", err.Error())
+			}
+		case "typing":
+			var payload struct {
+				RoomID uint `json:"room_id"`
+			}
+			if err := remarshal(msg.Payload, &payload); err != nil || !c.inRoom(payload.RoomID) {
+				continue
+			}
+			typing, err := json.Marshal(Message{
+				Type:    "typing",
+				Payload: map[string]uint{"room_id": payload.RoomID, "user_id": c.userID},
+			})
+			if err != nil {
+				continue
+			}
+			c.hub.broadcastToRoom(payload.RoomID, c.userID, typing, nil)
+		default:
+			c.sendError("unknown_type", fmt.Sprintf("Unknown message type %q", msg.Type))
 		}
 	}
 }
@@ -133,6 +192,9 @@ func (c *Client) writePump() {
 func (c *Client) joinRoom(roomID uint) {
 	c.roomsMux.Lock()
 	defer c.roomsMux.Unlock()
+	if c.closed {
+		return
+	}
 	c.rooms[roomID] = true
 	c.hub.joinRoom(c, roomID)
 }
@@ -152,12 +214,68 @@ func (c *Client) inRoom(roomID uint) bool {
 	return c.rooms[roomID]
 }
 
-// parseRoomID converts a string room ID to uint
-func parseRoomID(roomID string) uint {
-	id, err := strconv.ParseUint(roomID, 10, 64)
+// setBlocked records whether the client's user has blocked another user
+func (c *Client) setBlocked(userID uint, blocked bool) {
+	c.blockMux.Lock()
+	defer c.blockMux.Unlock()
+	if blocked {
+		c.blocked[userID] = true
+	} else {
+		delete(c.blocked, userID)
+	}
+}
+
+// hasBlocked checks if the client's user has blocked another user
+func (c *Client) hasBlocked(userID uint) bool {
+	c.blockMux.RLock()
+	defer c.blockMux.RUnlock()
+	return c.blocked[userID]
+}
+
+// remarshal decodes a generically decoded JSON value into a typed destination
+func remarshal(value interface{}, dest interface{}) error {
+	data, err := json.Marshal(value)
 	if err != nil {
-		log.Printf("error parsing room ID: %v", err)
-		return 0
+		return err
 	}
-	return uint(id)
+	return json.Unmarshal(data, dest)
+}
+
+// sendError sends an error frame to the client
+func (c *Client) sendError(code, message string) {
+	msgBytes, err := json.Marshal(Message{
+		Type:    "error",
+		Payload: map[string]string{"code": code, "message": message},
+	})
+	if err != nil {
+		log.Printf("error marshaling error frame: %v", err)
+		return
+	}
+	c.hub.deliver(c, msgBytes)
+}
+
+// parseRoomID reads a room ID given as a number, a numeric string, or an object with a room_id field
+func parseRoomID(payload interface{}) (uint, error) {
+	if obj, ok := payload.(map[string]interface{}); ok {
+		payload = obj["room_id"]
+	}
+
+	switch v := payload.(type) {
+	case float64:
+		if v >= 1 && v <= math.MaxUint32 && v == math.Trunc(v) {
+			return uint(v), nil
+		}
+	case string:
+		if id, err := strconv.ParseUint(v, 10, 32); err == nil && id > 0 {
+			return uint(id), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid room ID %v", payload)
+}
+
+// isRoomMember checks the database for a user's membership of a room
+func isRoomMember(userID, roomID uint) bool {
+	var count int64
+	database.DB.Model(&models.RoomUser{}).Where("room_id = ? AND user_id = ?", roomID, userID).Count(&count)
+	return count > 0
 }
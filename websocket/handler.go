@@ -3,8 +3,11 @@ package websocket
 import (
 	"log"
 	"net/http"
-	"strconv"
+	"strings"
 
+	"github.com/CUknot/network_backend/database"
+	"github.com/CUknot/network_backend/models"
+	"github.com/CUknot/network_backend/utils"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 )
@@ -24,19 +27,33 @@ func init() {
 
 // HandleConnection handles websocket connections
 func HandleConnection(c *gin.Context) {
-	// Get user ID from query parameter
-	userIDStr := c.Query("user_id")
-	if userIDStr == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id is required"})
+	// Browsers cannot set headers on websocket requests, so accept the token as a query parameter
+	token := c.Query("token")
+	if token == "" {
+		token = strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	}
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "token is required"})
 		return
 	}
 
-	userID, err := strconv.ParseUint(userIDStr, 10, 32)
+	session, err := utils.AuthenticateToken(token)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
 		return
 	}
 
+	// Load the users whose messages this client must not receive
+	var blockedIDs []uint
+	if err := database.DB.Model(&models.Block{}).Where("blocker_id = ?", session.UserID).Pluck("blocked_id", &blockedIDs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load blocked users"})
+		return
+	}
+	blocked := make(map[uint]bool, len(blockedIDs))
+	for _, id := range blockedIDs {
+		blocked[id] = true
+	}
+
 	// Upgrade HTTP connection to WebSocket
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
@@ -46,16 +63,28 @@ func HandleConnection(c *gin.Context) {
 
 	// Create a new client
 	client := &Client{
-		hub:    hub,
-		conn:   conn,
-		send:   make(chan []byte, 256),
-		userID: uint(userID),
-		rooms:  make(map[uint]bool),
+		hub:       hub,
+		conn:      conn,
+		send:      make(chan []byte, 256),
+		userID:    session.UserID,
+		sessionID: session.ID,
+		rooms:     make(map[uint]bool),
+		blocked:   blocked,
+		autoJoin:  c.Query("auto_join") == "true",
 	}
 
 	// Register client
 	client.hub.register <- client
 
+	// Subscribe to all of the user's rooms when asked to
+	if client.autoJoin {
+		var roomIDs []uint
+		database.DB.Model(&models.RoomUser{}).Where("user_id = ?", client.userID).Pluck("room_id", &roomIDs)
+		for _, roomID := range roomIDs {
+			client.joinRoom(roomID)
+		}
+	}
+
 	// Start goroutines for reading and writing
 	go client.readPump()
 	go client.writePump()
@@ -11,6 +11,12 @@ type Hub struct {
 	// Registered clients
 	clients map[*Client]bool
 
+	// Users mapping (userID -> clients)
+	users map[uint]map[*Client]bool
+
+	// Mutex for users map
+	usersMux sync.RWMutex
+
 	// Rooms mapping (roomID -> clients)
 	rooms map[uint]map[*Client]bool
 
@@ -18,22 +24,33 @@ type Hub struct {
 	roomsMux sync.RWMutex
 
 	// Inbound messages from the clients
-	broadcast chan []byte
+	broadcast chan clientMessage
 
 	// Register requests from the clients
 	register chan *Client
 
 	// Unregister requests from clients
 	unregister chan *Client
+
+	// Revoked sessions whose clients must be disconnected
+	revoke chan uint
+}
+
+// clientMessage is an inbound message together with the client that sent it
+type clientMessage struct {
+	client *Client
+	data   []byte
 }
 
 // NewHub creates a new hub instance
 func NewHub() *Hub {
 	return &Hub{
-		broadcast:  make(chan []byte),
+		broadcast:  make(chan clientMessage),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
+		revoke:     make(chan uint),
 		clients:    make(map[*Client]bool),
+		users:      make(map[uint]map[*Client]bool),
 		rooms:      make(map[uint]map[*Client]bool),
 	}
 }
@@ -44,28 +61,27 @@ func (h *Hub) Run() {
 		select {
 		case client := <-h.register:
 			h.clients[client] = true
+
+			h.usersMux.Lock()
+			if _, ok := h.users[client.userID]; !ok {
+				h.users[client.userID] = make(map[*Client]bool)
+			}
+			h.users[client.userID][client] = true
+			h.usersMux.Unlock()
 		case client := <-h.unregister:
 			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				close(client.send)
-
-				// Remove client from all rooms
-				h.roomsMux.Lock()
-				for roomID, clients := range h.rooms {
-					if _, ok := clients[client]; ok {
-						delete(h.rooms[roomID], client)
-						// Clean up empty rooms
-						if len(h.rooms[roomID]) == 0 {
-							delete(h.rooms, roomID)
-						}
-					}
+				h.removeClient(client)
+			}
+		case sessionID := <-h.revoke:
+			for client := range h.clients {
+				if client.sessionID == sessionID {
+					h.removeClient(client)
 				}
-				h.roomsMux.Unlock()
 			}
-		case message := <-h.broadcast:
+		case inbound := <-h.broadcast:
 			// Parse the message to determine which room to broadcast to
 			var msg Message
-			if err := json.Unmarshal(message, &msg); err != nil {
+			if err := json.Unmarshal(inbound.data, &msg); err != nil {
 				log.Printf("error unmarshaling broadcast message: %v", err)
 				continue
 			}
@@ -77,22 +93,50 @@ func (h *Hub) Run() {
 					RoomID uint `json:"room_id"`
 				}
 
-				payloadBytes, err := json.Marshal(msg.Payload)
-				if err != nil {
-					log.Printf("error marshaling payload: %v", err)
-					continue
-				}
-
-				if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+				if err := remarshal(msg.Payload, &payload); err != nil {
 					log.Printf("error unmarshaling payload: %v", err)
 					continue
 				}
 
 				// Broadcast to specific room
-				h.broadcastToRoom(payload.RoomID, message)
+				h.broadcastToRoom(payload.RoomID, inbound.client.userID, inbound.data, nil)
+			}
+		}
+	}
+}
+
+// removeClient drops a client from the hub and all of its rooms, closing its connection
+func (h *Hub) removeClient(client *Client) {
+	delete(h.clients, client)
+
+	// Stop the client from joining further rooms
+	client.roomsMux.Lock()
+	client.closed = true
+	client.roomsMux.Unlock()
+
+	// Remove client from its user's connections
+	h.usersMux.Lock()
+	delete(h.users[client.userID], client)
+	if len(h.users[client.userID]) == 0 {
+		delete(h.users, client.userID)
+	}
+	h.usersMux.Unlock()
+
+	// Remove client from all rooms
+	h.roomsMux.Lock()
+	for roomID, clients := range h.rooms {
+		if _, ok := clients[client]; ok {
+			delete(h.rooms[roomID], client)
+			// Clean up empty rooms
+			if len(h.rooms[roomID]) == 0 {
+				delete(h.rooms, roomID)
 			}
 		}
 	}
+	h.roomsMux.Unlock()
+
+	// Nothing can reach the client any more, so its channel can be closed
+	close(client.send)
 }
 
 // joinRoom adds a client to a room
@@ -120,26 +164,110 @@ func (h *Hub) leaveRoom(client *Client, roomID uint) {
 	}
 }
 
-// broadcastToRoom sends a message to all clients in a room
-func (h *Hub) broadcastToRoom(roomID uint, message []byte) {
+// broadcastToRoom sends a message to all clients in a room except those whose
+// user has blocked the sender; a senderID of 0 reaches everyone. A non-nil
+// include further limits delivery to the users it accepts.
+func (h *Hub) broadcastToRoom(roomID uint, senderID uint, message []byte, include func(userID uint) bool) {
 	h.roomsMux.RLock()
 	defer h.roomsMux.RUnlock()
 
 	if clients, ok := h.rooms[roomID]; ok {
 		for client := range clients {
-			select {
-			case client.send <- message:
-			default:
-				close(client.send)
-				delete(clients, client)
-				delete(h.clients, client)
+			if senderID != 0 && client.hasBlocked(senderID) {
+				continue
 			}
+			if include != nil && !include(client.userID) {
+				continue
+			}
+			h.deliver(client, message)
 		}
 	}
 }
 
+// userClients returns a snapshot of a user's connections
+func (h *Hub) userClients(userID uint) []*Client {
+	h.usersMux.RLock()
+	defer h.usersMux.RUnlock()
+
+	clients := make([]*Client, 0, len(h.users[userID]))
+	for client := range h.users[userID] {
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+// setBlocked updates the block list of every connection of a user
+func (h *Hub) setBlocked(userID, blockedID uint, blocked bool) {
+	h.usersMux.RLock()
+	defer h.usersMux.RUnlock()
+
+	for client := range h.users[userID] {
+		client.setBlocked(blockedID, blocked)
+	}
+}
+
+// sendToUser sends a message to every connection of a user
+func (h *Hub) sendToUser(userID uint, message []byte) {
+	h.usersMux.RLock()
+	defer h.usersMux.RUnlock()
+
+	for client := range h.users[userID] {
+		h.deliver(client, message)
+	}
+}
+
+// isOnline reports whether a user has at least one live connection
+func (h *Hub) isOnline(userID uint) bool {
+	h.usersMux.RLock()
+	defer h.usersMux.RUnlock()
+	return len(h.users[userID]) > 0
+}
+
+// deliver queues a message for a client, disconnecting clients that cannot keep up
+func (h *Hub) deliver(client *Client, message []byte) {
+	select {
+	case client.send <- message:
+	default:
+		// Only the Run loop may close a client's channel
+		go func() { h.unregister <- client }()
+	}
+}
+
 // BroadcastToRoom sends a message to all clients in a room
 func BroadcastToRoom(roomID uint, msgType string, payload interface{}) {
+	BroadcastToRoomFrom(roomID, 0, msgType, payload)
+}
+
+// BroadcastToRoomFrom sends a message caused by a user to all clients in a room
+// except those whose user has blocked the sender
+func BroadcastToRoomFrom(roomID uint, senderID uint, msgType string, payload interface{}) {
+	BroadcastToRoomWhere(roomID, senderID, nil, msgType, payload)
+}
+
+// BroadcastToRoomWhere is BroadcastToRoomFrom limited to the users include accepts,
+// for payloads that differ between members of a room
+func BroadcastToRoomWhere(roomID uint, senderID uint, include func(userID uint) bool, msgType string, payload interface{}) {
+	msg := Message{
+		Type:    msgType,
+		Payload: payload,
+	}
+
+	msgBytes, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("error marshaling message: %v", err)
+		return
+	}
+
+	hub.broadcastToRoom(roomID, senderID, msgBytes, include)
+}
+
+// SetBlocked updates the live connections of a user after they block or unblock someone
+func SetBlocked(userID, blockedID uint, blocked bool) {
+	hub.setBlocked(userID, blockedID, blocked)
+}
+
+// SendToUser sends a message to every connection of a user, regardless of joined rooms
+func SendToUser(userID uint, msgType string, payload interface{}) {
 	msg := Message{
 		Type:    msgType,
 		Payload: payload,
@@ -151,7 +279,33 @@ func BroadcastToRoom(roomID uint, msgType string, payload interface{}) {
 		return
 	}
 
-	hub.broadcastToRoom(roomID, msgBytes)
+	hub.sendToUser(userID, msgBytes)
+}
+
+// IsOnline reports whether a user has at least one live websocket connection
+func IsOnline(userID uint) bool {
+	return hub.isOnline(userID)
+}
+
+// AddUserToRoom subscribes a user's auto-joining connections to a room they were added to
+func AddUserToRoom(userID, roomID uint) {
+	for _, client := range hub.userClients(userID) {
+		if client.autoJoin {
+			client.joinRoom(roomID)
+		}
+	}
+}
+
+// RemoveUserFromRoom unsubscribes all of a user's connections from a room they no longer belong to
+func RemoveUserFromRoom(userID, roomID uint) {
+	for _, client := range hub.userClients(userID) {
+		client.leaveRoom(roomID)
+	}
+}
+
+// DisconnectSession closes every websocket connection opened with a session
+func DisconnectSession(sessionID uint) {
+	hub.revoke <- sessionID
 }
 
 // Global hub instance